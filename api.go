@@ -3,25 +3,55 @@ package main
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // used for generating salt
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 type SubsonicConnection struct {
-	Username       string
-	Password       string
-	Host           string
-	PlaintextAuth  bool
-	Scrobble       bool
-	Logger         Logger
+	// name of the server profile the connection was made from
+	ProfileName   string
+	Username      string
+	Password      string
+	Host          string
+	PlaintextAuth bool
+	// OpenSubsonic API key, used instead of the username and password
+	ApiKey   string
+	Scrobble bool
+	Client   *http.Client
+	// downloads songs, without the overall timeout of Client. Client is used
+	// if it isn't set.
+	DownloadClient *http.Client
+	// number of times idempotent requests are retried
+	Retries int
+	// streams are transcoded down to MaxBitRate kbps, in Format, when set
+	MaxBitRate int
+	Format     string
+	// limits lists, random songs and searches to one music folder when set
+	MusicFolderId string
+	Logger        Logger
+	// the connection is used from the GUI and from the mpv event loop, so
+	// the cache and the requests in flight are guarded by cacheLock
+	cacheLock      sync.Mutex
 	directoryCache map[string]SubsonicResponse
+	inFlight       map[string]*inFlightRequest
+	// keeps responses between sessions, if set
+	Cache *MetadataCache
+	// downloaded songs, played instead of streaming them, if set
+	Tracks *TrackCache
+	// OpenSubsonic extensions the server supports, with their versions
+	extensions map[string][]int
 }
 
 func randSeq(n int) string {
@@ -41,14 +71,19 @@ func authToken(password string) (string, string) {
 
 func defaultQuery(connection *SubsonicConnection) url.Values {
 	query := url.Values{}
-	if connection.PlaintextAuth {
+	if connection.ApiKey != "" {
+		// the username is part of the key, and must not be sent with it
+		query.Set("apiKey", connection.ApiKey)
+	} else if connection.PlaintextAuth {
 		query.Set("p", connection.Password)
 	} else {
 		token, salt := authToken(connection.Password)
 		query.Set("t", token)
 		query.Set("s", salt)
 	}
-	query.Set("u", connection.Username)
+	if connection.ApiKey == "" {
+		query.Set("u", connection.Username)
+	}
 	query.Set("v", "1.15.1")
 	query.Set("c", "stmp")
 	query.Set("f", "json")
@@ -62,10 +97,103 @@ type SubsonicError struct {
 	Message string `json:"message"`
 }
 
+// error codes of failed responses
+const (
+	ErrorGeneric              = 0
+	ErrorMissingParameter     = 10
+	ErrorClientTooOld         = 20
+	ErrorServerTooOld         = 30
+	ErrorWrongCredentials     = 40
+	ErrorTokenAuthUnsupported = 41
+	ErrorAuthUnsupported      = 42
+	ErrorConflictingAuth      = 43
+	ErrorInvalidApiKey        = 44
+	ErrorNotAuthorized        = 50
+	ErrorTrialExpired         = 60
+	ErrorNotFound             = 70
+)
+
+// SubsonicAPIError is returned for requests the server failed, either with a
+// failed response or with an HTTP error
+type SubsonicAPIError struct {
+	// Subsonic error code, only meaningful if the server sent a response
+	Code       int
+	Message    string
+	HTTPStatus int
+}
+
+func (e *SubsonicAPIError) Error() string {
+	var description string
+	switch e.Code {
+	case ErrorMissingParameter:
+		description = "missing parameter"
+	case ErrorClientTooOld:
+		description = "stmp is too old for the server"
+	case ErrorServerTooOld:
+		description = "the server is too old for stmp"
+	case ErrorWrongCredentials:
+		description = "wrong username or password"
+	case ErrorTokenAuthUnsupported:
+		description = "the server doesn't support token authentication, try auth.plaintext"
+	case ErrorAuthUnsupported:
+		description = "the server doesn't support this authentication method"
+	case ErrorConflictingAuth:
+		description = "conflicting authentication methods"
+	case ErrorInvalidApiKey:
+		description = "invalid API key"
+	case ErrorNotAuthorized:
+		description = "not authorized"
+	case ErrorTrialExpired:
+		description = "the server's trial period is over"
+	case ErrorNotFound:
+		description = "not found"
+	default:
+		if e.HTTPStatus != http.StatusOK {
+			description = fmt.Sprintf("HTTP %d %s", e.HTTPStatus, http.StatusText(e.HTTPStatus))
+		} else {
+			description = "server error"
+		}
+	}
+
+	if e.Message == "" {
+		return description
+	}
+	return description + ": " + e.Message
+}
+
+// IsNotFound reports whether the server failed a request because what it
+// asked for doesn't exist, which includes endpoints the server doesn't have
+func IsNotFound(err error) bool {
+	var apiError *SubsonicAPIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	return apiError.Code == ErrorNotFound || apiError.HTTPStatus == http.StatusNotFound
+}
+
 type SubsonicArtist struct {
-	Id         string
-	Name       string
-	AlbumCount int
+	Id            string
+	Name          string
+	AlbumCount    int
+	UserRating    int             `json:"userRating"`
+	AverageRating float64         `json:"averageRating"`
+	CoverArt      string          `json:"coverArt"`
+	Albums        []SubsonicAlbum `json:"album"`
+}
+
+type SubsonicAlbum struct {
+	Id            string           `json:"id"`
+	Name          string           `json:"name"`
+	Artist        string           `json:"artist"`
+	ArtistId      string           `json:"artistId"`
+	SongCount     int              `json:"songCount"`
+	Duration      int              `json:"duration"`
+	Year          int              `json:"year"`
+	Genre         string           `json:"genre"`
+	UserRating    int              `json:"userRating"`
+	AverageRating float64          `json:"averageRating"`
+	CoverArt      string           `json:"coverArt"`
+	Songs         SubsonicEntities `json:"song"`
 }
 
 type SubsonicDirectory struct {
@@ -79,8 +207,186 @@ type SubsonicSongs struct {
 	Song SubsonicEntities `json:"song"`
 }
 
+type SubsonicAlbumList struct {
+	Albums []SubsonicAlbum `json:"album"`
+}
+
+type SubsonicSearchResult struct {
+	Artists []SubsonicArtist `json:"artist"`
+	Albums  []SubsonicAlbum  `json:"album"`
+	Songs   SubsonicEntities `json:"song"`
+}
+
+type SubsonicGenres struct {
+	Genres []SubsonicGenre `json:"genre"`
+}
+
+type SubsonicGenre struct {
+	Name       string `json:"value"`
+	SongCount  int    `json:"songCount"`
+	AlbumCount int    `json:"albumCount"`
+}
+
+type SubsonicRadioStations struct {
+	Stations []SubsonicRadioStation `json:"internetRadioStation"`
+}
+
+type SubsonicRadioStation struct {
+	Id          SubsonicId `json:"id"`
+	Name        string     `json:"name"`
+	StreamUrl   string     `json:"streamUrl"`
+	HomePageUrl string     `json:"homePageUrl"`
+}
+
+type SubsonicPodcasts struct {
+	Channels []SubsonicPodcastChannel `json:"channel"`
+}
+
+type SubsonicPodcastChannel struct {
+	Id          SubsonicId               `json:"id"`
+	Url         string                   `json:"url"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description"`
+	Status      string                   `json:"status"`
+	Episodes    []SubsonicPodcastEpisode `json:"episode"`
+}
+
+type SubsonicNewestPodcasts struct {
+	Episodes []SubsonicPodcastEpisode `json:"episode"`
+}
+
+// download states of a podcast episode on the server
+const (
+	EpisodeNew         = "new"
+	EpisodeDownloading = "downloading"
+	EpisodeCompleted   = "completed"
+	EpisodeError       = "error"
+	EpisodeDeleted     = "deleted"
+	EpisodeSkipped     = "skipped"
+)
+
+type SubsonicPodcastEpisode struct {
+	Id          SubsonicId `json:"id"`
+	StreamId    string     `json:"streamId"`
+	ChannelId   string     `json:"channelId"`
+	Title       string     `json:"title"`
+	Artist      string     `json:"artist"`
+	Description string     `json:"description"`
+	PublishDate string     `json:"publishDate"`
+	Status      string     `json:"status"`
+	Duration    int        `json:"duration"`
+	CoverArt    string     `json:"coverArt"`
+}
+
+type SubsonicPlayQueue struct {
+	Current   SubsonicId       `json:"current"`
+	Position  int64            `json:"position"`
+	Changed   string           `json:"changed"`
+	ChangedBy string           `json:"changedBy"`
+	Entries   SubsonicEntities `json:"entry"`
+}
+
+type SubsonicBookmarks struct {
+	Bookmarks []SubsonicBookmark `json:"bookmark"`
+}
+
+type SubsonicBookmark struct {
+	Position int64          `json:"position"`
+	Comment  string         `json:"comment"`
+	Created  string         `json:"created"`
+	Changed  string         `json:"changed"`
+	Entry    SubsonicEntity `json:"entry"`
+}
+
+type SubsonicExtension struct {
+	Name     string `json:"name"`
+	Versions []int  `json:"versions"`
+}
+
+// OpenSubsonic extensions stmp makes use of. None of the transcoding ones
+// are: streams are transcoded with the streaming settings whatever the server
+// supports.
+const (
+	ExtensionApiKey     = "apiKeyAuthentication"
+	ExtensionFormPost   = "formPost"
+	ExtensionSongLyrics = "songLyrics"
+)
+
+type SubsonicMusicFolders struct {
+	Folders []SubsonicMusicFolder `json:"musicFolder"`
+}
+
+type SubsonicMusicFolder struct {
+	Id   SubsonicId `json:"id"`
+	Name string     `json:"name"`
+}
+
+type SubsonicShares struct {
+	Shares []SubsonicShare `json:"share"`
+}
+
+type SubsonicShare struct {
+	Id          SubsonicId       `json:"id"`
+	Url         string           `json:"url"`
+	Description string           `json:"description"`
+	Username    string           `json:"username"`
+	Created     string           `json:"created"`
+	Expires     string           `json:"expires"`
+	LastVisited string           `json:"lastVisited"`
+	VisitCount  int              `json:"visitCount"`
+	Entries     SubsonicEntities `json:"entry"`
+}
+
+// SubsonicLyricsList holds the lyrics returned by getLyricsBySongId, which may
+// come in several languages
+type SubsonicLyricsList struct {
+	StructuredLyrics []SubsonicStructuredLyrics `json:"structuredLyrics"`
+}
+
+type SubsonicStructuredLyrics struct {
+	DisplayArtist string `json:"displayArtist"`
+	DisplayTitle  string `json:"displayTitle"`
+	Lang          string `json:"lang"`
+	// milliseconds to subtract from the start of every line
+	Offset int64                `json:"offset"`
+	Synced bool                 `json:"synced"`
+	Lines  []SubsonicLyricsLine `json:"line"`
+}
+
+type SubsonicLyricsLine struct {
+	// milliseconds into the song the line is sung, only set on synced lyrics
+	Start int64  `json:"start"`
+	Value string `json:"value"`
+}
+
+// SubsonicLyrics holds the plain text lyrics returned by the legacy getLyrics
+type SubsonicLyrics struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Value  string `json:"value"`
+}
+
+type SubsonicUser struct {
+	Username     string `json:"username"`
+	AdminRole    bool   `json:"adminRole"`
+	SettingsRole bool   `json:"settingsRole"`
+	DownloadRole bool   `json:"downloadRole"`
+	UploadRole   bool   `json:"uploadRole"`
+	PlaylistRole bool   `json:"playlistRole"`
+	CoverArtRole bool   `json:"coverArtRole"`
+	CommentRole  bool   `json:"commentRole"`
+	PodcastRole  bool   `json:"podcastRole"`
+	StreamRole   bool   `json:"streamRole"`
+	JukeboxRole  bool   `json:"jukeboxRole"`
+	ShareRole    bool   `json:"shareRole"`
+}
+
+// SubsonicStarred holds the starred artists, albums and songs, with artists
+// and albums by their ID3 tags
 type SubsonicStarred struct {
-	Starred SubsonicEntities `json:"starred"`
+	Artists []SubsonicArtist `json:"artist"`
+	Albums  []SubsonicAlbum  `json:"album"`
+	Songs   SubsonicEntities `json:"song"`
 }
 
 type SubsonicEntity struct {
@@ -89,10 +395,19 @@ type SubsonicEntity struct {
 	Parent      string `json:"parent"`
 	Title       string `json:"title"`
 	Artist      string `json:"artist"`
+	Album       string `json:"album"`
 	Duration    int    `json:"duration"`
 	Track       int    `json:"track"`
 	DiskNumber  int    `json:"diskNumber"`
 	Path        string `json:"path"`
+	CoverArt    string `json:"coverArt"`
+	// the rating given by the user, from 1 to 5, or 0 when unrated
+	UserRating    int     `json:"userRating"`
+	AverageRating float64 `json:"averageRating"`
+
+	// set on albums found by their ID3 tags, which have to be fetched with
+	// getAlbum rather than getMusicDirectory
+	isAlbum bool
 }
 
 // SubsonicEntities is a sortable list of entities.
@@ -120,6 +435,8 @@ func (s SubsonicEntities) Less(i, j int) bool {
 
 type SubsonicIndexes struct {
 	Index []SubsonicIndex
+	// when the library last changed, in milliseconds since the epoch
+	LastModified int64 `json:"lastModified"`
 }
 
 type SubsonicIndex struct {
@@ -139,15 +456,39 @@ type SubsonicPlaylist struct {
 }
 
 type SubsonicResponse struct {
-	Status      string            `json:"status"`
-	Version     string            `json:"version"`
-	Indexes     SubsonicIndexes   `json:"indexes"`
-	Directory   SubsonicDirectory `json:"directory"`
-	RandomSongs SubsonicSongs     `json:"randomSongs"`
-	Starred     SubsonicSongs     `json:"starred"`
-	Playlists   SubsonicPlaylists `json:"playlists"`
-	Playlist    SubsonicPlaylist  `json:"playlist"`
-	Error       SubsonicError     `json:"error"`
+	Status         string                 `json:"status"`
+	Version        string                 `json:"version"`
+	OpenSubsonic   bool                   `json:"openSubsonic"`
+	ServerType     string                 `json:"type"`
+	ServerVersion  string                 `json:"serverVersion"`
+	Extensions     []SubsonicExtension    `json:"openSubsonicExtensions"`
+	Indexes        SubsonicIndexes        `json:"indexes"`
+	Artists        SubsonicIndexes        `json:"artists"`
+	AlbumList      SubsonicAlbumList      `json:"albumList2"`
+	Artist         SubsonicArtist         `json:"artist"`
+	Album          SubsonicAlbum          `json:"album"`
+	Directory      SubsonicDirectory      `json:"directory"`
+	RandomSongs    SubsonicSongs          `json:"randomSongs"`
+	SongsByGenre   SubsonicSongs          `json:"songsByGenre"`
+	SimilarSongs   SubsonicSongs          `json:"similarSongs"`
+	SimilarSongs2  SubsonicSongs          `json:"similarSongs2"`
+	TopSongs       SubsonicSongs          `json:"topSongs"`
+	Genres         SubsonicGenres         `json:"genres"`
+	Starred        SubsonicStarred        `json:"starred2"`
+	Playlists      SubsonicPlaylists      `json:"playlists"`
+	SearchResult   SubsonicSearchResult   `json:"searchResult3"`
+	Playlist       SubsonicPlaylist       `json:"playlist"`
+	RadioStations  SubsonicRadioStations  `json:"internetRadioStations"`
+	User           SubsonicUser           `json:"user"`
+	Podcasts       SubsonicPodcasts       `json:"podcasts"`
+	NewestPodcasts SubsonicNewestPodcasts `json:"newestPodcasts"`
+	PlayQueue      SubsonicPlayQueue      `json:"playQueue"`
+	Bookmarks      SubsonicBookmarks      `json:"bookmarks"`
+	LyricsList     SubsonicLyricsList     `json:"lyricsList"`
+	Shares         SubsonicShares         `json:"shares"`
+	MusicFolders   SubsonicMusicFolders   `json:"musicFolders"`
+	Lyrics         SubsonicLyrics         `json:"lyrics"`
+	Error          SubsonicError          `json:"error"`
 }
 
 type responseWrapper struct {
@@ -176,37 +517,337 @@ func (connection *SubsonicConnection) GetServerInfo() (*SubsonicResponse, error)
 	return connection.getResponse("GetServerInfo", requestUrl)
 }
 
-func (connection *SubsonicConnection) GetIndexes() (*SubsonicResponse, error) {
+func (connection *SubsonicConnection) GetOpenSubsonicExtensions() (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-	requestUrl := connection.Host + "/rest/getIndexes" + "?" + query.Encode()
-	return connection.getResponse("GetIndexes", requestUrl)
+	requestUrl := connection.Host + "/rest/getOpenSubsonicExtensions" + "?" + query.Encode()
+	return connection.getResponse("GetOpenSubsonicExtensions", requestUrl)
 }
 
-func (connection *SubsonicConnection) GetMusicDirectory(id string) (*SubsonicResponse, error) {
-	if cachedResponse, present := connection.directoryCache[id]; present {
-		return &cachedResponse, nil
+// DetectExtensions records the OpenSubsonic extensions the server supports.
+// Servers that aren't OpenSubsonic support none of them.
+func (connection *SubsonicConnection) DetectExtensions() error {
+	connection.extensions = make(map[string][]int)
+
+	response, err := connection.GetOpenSubsonicExtensions()
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, extension := range response.Extensions {
+		connection.extensions[extension.Name] = extension.Versions
+	}
+	return nil
+}
+
+// HasExtension reports whether the server supports an OpenSubsonic extension
+func (connection *SubsonicConnection) HasExtension(name string) bool {
+	_, ok := connection.extensions[name]
+	return ok
+}
+
+// setMusicFolder limits the request to the selected music folder, if any
+func (connection *SubsonicConnection) setMusicFolder(query url.Values) {
+	if connection.MusicFolderId != "" {
+		query.Set("musicFolderId", connection.MusicFolderId)
 	}
+}
 
+func (connection *SubsonicConnection) GetMusicFolders() (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-	query.Set("id", id)
-	requestUrl := connection.Host + "/rest/getMusicDirectory" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetMusicDirectory", requestUrl)
+	requestUrl := connection.Host + "/rest/getMusicFolders" + "?" + query.Encode()
+	return connection.getResponse("GetMusicFolders", requestUrl)
+}
+
+// inFlightRequest is a request for a cached response that other callers
+// wanting the same response wait for, rather than making it again
+type inFlightRequest struct {
+	done     chan struct{}
+	response *SubsonicResponse
+	err      error
+}
+
+// cached returns a response cached during this session, or on disk if it was
+// cached there less than ttl ago
+func (connection *SubsonicConnection) cached(key string, ttl time.Duration) (*SubsonicResponse, bool) {
+	connection.cacheLock.Lock()
+	cachedResponse, present := connection.directoryCache[key]
+	connection.cacheLock.Unlock()
+	if present {
+		return copyResponse(&cachedResponse), true
+	}
+	if connection.Cache == nil {
+		return nil, false
+	}
+
+	loadedResponse, present := connection.Cache.Load(key, ttl)
+	if present {
+		connection.cacheLock.Lock()
+		connection.directoryCache[key] = *copyResponse(loadedResponse)
+		connection.cacheLock.Unlock()
+	}
+	return loadedResponse, present
+}
+
+func (connection *SubsonicConnection) storeCached(key string, response *SubsonicResponse) {
+	connection.cacheLock.Lock()
+	connection.directoryCache[key] = *copyResponse(response)
+	connection.cacheLock.Unlock()
+
+	if connection.Cache == nil {
+		return
+	}
+	if err := connection.Cache.Store(key, response); err != nil {
+		connection.Logger.Printf("storeCached: %s -- %s", key, err.Error())
+	}
+}
+
+// copyResponse copies the lists of the responses that are cached, so the
+// copy can be sorted or otherwise changed without touching the original
+func copyResponse(response *SubsonicResponse) *SubsonicResponse {
+	copied := *response
+	copied.Directory.Entities = append(SubsonicEntities(nil), response.Directory.Entities...)
+	copied.Album.Songs = append(SubsonicEntities(nil), response.Album.Songs...)
+	copied.Artist.Albums = append([]SubsonicAlbum(nil), response.Artist.Albums...)
+	copied.Indexes.Index = append([]SubsonicIndex(nil), response.Indexes.Index...)
+	for i := range copied.Indexes.Index {
+		copied.Indexes.Index[i].Artists = append([]SubsonicArtist(nil), copied.Indexes.Index[i].Artists...)
+	}
+	return &copied
+}
+
+// getCachedResponse returns the response cached under key, or requests it and
+// caches it
+func (connection *SubsonicConnection) getCachedResponse(key, caller, requestUrl string) (*SubsonicResponse, error) {
+	if cachedResponse, present := connection.cached(key, connection.directoryTTL()); present {
+		return cachedResponse, nil
+	}
+
+	return connection.shareRequest(key, func() (*SubsonicResponse, error) {
+		response, err := connection.getResponse(caller, requestUrl)
+		if err == nil {
+			connection.storeCached(key, response)
+		} else if staleResponse, present := connection.staleCached(key, err); present {
+			return staleResponse, nil
+		}
+		return response, err
+	})
+}
+
+// shareRequest returns the response of fetch. Callers asking for a key that
+// is already being fetched wait for it and share its response, rather than
+// fetching it again. Every caller gets a copy of its own.
+func (connection *SubsonicConnection) shareRequest(key string, fetch func() (*SubsonicResponse, error)) (*SubsonicResponse, error) {
+	connection.cacheLock.Lock()
+	if request, ok := connection.inFlight[key]; ok {
+		connection.cacheLock.Unlock()
+		<-request.done
+		if request.err != nil {
+			return nil, request.err
+		}
+		return copyResponse(request.response), nil
+	}
+	if connection.inFlight == nil {
+		connection.inFlight = make(map[string]*inFlightRequest)
+	}
+	request := &inFlightRequest{done: make(chan struct{})}
+	connection.inFlight[key] = request
+	connection.cacheLock.Unlock()
+
+	request.response, request.err = fetch()
+
+	connection.cacheLock.Lock()
+	delete(connection.inFlight, key)
+	connection.cacheLock.Unlock()
+	close(request.done)
+
+	if request.err != nil {
+		return nil, request.err
+	}
+	return copyResponse(request.response), nil
+}
+
+// staleCached returns a response cached on disk however old it is, if err
+// means the server couldn't be reached, so the library can still be browsed
+// offline
+func (connection *SubsonicConnection) staleCached(key string, err error) (*SubsonicResponse, bool) {
+	var apiError *SubsonicAPIError
+	if connection.Cache == nil || errors.As(err, &apiError) {
+		return nil, false
+	}
+	return connection.Cache.Load(key, 0)
+}
+
+// forgetCached drops a response from the caches, so it is fetched again
+func (connection *SubsonicConnection) forgetCached(key string) {
+	connection.cacheLock.Lock()
+	delete(connection.directoryCache, key)
+	connection.cacheLock.Unlock()
+
+	if connection.Cache != nil {
+		connection.Cache.Remove(key)
+	}
+}
+
+// ClearCache drops every cached response
+func (connection *SubsonicConnection) ClearCache() {
+	connection.cacheLock.Lock()
+	connection.directoryCache = make(map[string]SubsonicResponse)
+	connection.cacheLock.Unlock()
+
+	if connection.Cache == nil {
+		return
+	}
+	if err := connection.Cache.Clear(); err != nil {
+		connection.Logger.Printf("ClearCache -- %s", err.Error())
+	}
+}
+
+// GetIndexes returns the artists of the library by folder. The indexes are
+// cached, and only fetched again once the server says the library changed,
+// which also drops every cached directory.
+func (connection *SubsonicConnection) GetIndexes() (*SubsonicResponse, error) {
+	cacheKey := "indexes-" + connection.MusicFolderId
+	return connection.shareRequest(cacheKey, func() (*SubsonicResponse, error) {
+		return connection.fetchIndexes(cacheKey)
+	})
+}
+
+func (connection *SubsonicConnection) fetchIndexes(cacheKey string) (*SubsonicResponse, error) {
+	var cachedResponse *SubsonicResponse
+	if connection.Cache != nil {
+		cachedResponse, _ = connection.Cache.Load(cacheKey, 0)
+	}
+
+	query := defaultQuery(connection)
+	connection.setMusicFolder(query)
+	if cachedResponse != nil {
+		query.Set("ifModifiedSince", strconv.FormatInt(cachedResponse.Indexes.LastModified, 10))
+	}
+	requestUrl := connection.Host + "/rest/getIndexes" + "?" + query.Encode()
+	resp, err := connection.getResponse("GetIndexes", requestUrl)
 	if err != nil {
+		if staleResponse, present := connection.staleCached(cacheKey, err); present {
+			return staleResponse, nil
+		}
 		return resp, err
 	}
-
-	// on a sucessful request, cache the response
-	if resp.Status == "ok" {
-		connection.directoryCache[id] = *resp
+	if connection.Cache == nil {
+		return resp, nil
 	}
 
+	if cachedResponse != nil {
+		// nothing but the modification time comes back if nothing changed
+		if resp.Indexes.LastModified <= cachedResponse.Indexes.LastModified {
+			if len(resp.Indexes.Index) == 0 {
+				return cachedResponse, nil
+			}
+		} else {
+			connection.ClearCache()
+		}
+	}
+	if err := connection.Cache.Store(cacheKey, resp); err != nil {
+		connection.Logger.Printf("GetIndexes: Store -- %s", err.Error())
+	}
 	return resp, nil
 }
 
-func (connection *SubsonicConnection) GetRandomSongs() (*SubsonicResponse, error) {
+func (connection *SubsonicConnection) GetMusicDirectory(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getMusicDirectory" + "?" + query.Encode()
+	return connection.getCachedResponse(id, "GetMusicDirectory", requestUrl)
+}
+
+// GetArtists returns the artists of the library, organized by ID3 tags
+// rather than by folder
+func (connection *SubsonicConnection) GetArtists() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getArtists" + "?" + query.Encode()
+	return connection.getResponse("GetArtists", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetArtist(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getArtist" + "?" + query.Encode()
+	// tag ids and folder ids may overlap, so they get their own cache keys
+	return connection.getCachedResponse("artist-"+id, "GetArtist", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetAlbum(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getAlbum" + "?" + query.Encode()
+	return connection.getCachedResponse("album-"+id, "GetAlbum", requestUrl)
+}
+
+// list types accepted by getAlbumList2
+const (
+	AlbumListNewest   = "newest"
+	AlbumListRecent   = "recent"
+	AlbumListFrequent = "frequent"
+	AlbumListHighest  = "highest"
+	AlbumListByName   = "alphabeticalByName"
+	AlbumListByArtist = "alphabeticalByArtist"
+	AlbumListRandom   = "random"
+	AlbumListStarred  = "starred"
+	AlbumListByYear   = "byYear"
+	AlbumListByGenre  = "byGenre"
+)
+
+// number of albums requested for each page of an album list
+const albumListPageSize = 50
+
+// GetAlbumList2 returns a page of albums, organized by ID3 tags, in the order
+// given by listType. genre is only used by AlbumListByGenre, and fromYear and
+// toYear only by AlbumListByYear.
+func (connection *SubsonicConnection) GetAlbumList2(listType string, offset int, genre string, fromYear int, toYear int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("type", listType)
+	query.Set("size", strconv.Itoa(albumListPageSize))
+	query.Set("offset", strconv.Itoa(offset))
+	connection.setMusicFolder(query)
+
+	switch listType {
+	case AlbumListByGenre:
+		query.Set("genre", genre)
+	case AlbumListByYear:
+		query.Set("fromYear", strconv.Itoa(fromYear))
+		query.Set("toYear", strconv.Itoa(toYear))
+	}
+
+	requestUrl := connection.Host + "/rest/getAlbumList2" + "?" + query.Encode()
+	return connection.getResponse("GetAlbumList2", requestUrl)
+}
+
+// RandomSongFilter narrows down the songs returned by GetRandomSongs. Empty
+// fields are left out of the request, so the server defaults apply.
+type RandomSongFilter struct {
+	Size     int
+	Genre    string
+	FromYear int
+	ToYear   int
+}
+
+func (connection *SubsonicConnection) GetRandomSongs(filter RandomSongFilter) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-        // Let's get 50 random songs, default is 10
-	query.Set("size", "50")
+	if filter.Size > 0 {
+		query.Set("size", strconv.Itoa(filter.Size))
+	}
+	if filter.Genre != "" {
+		query.Set("genre", filter.Genre)
+	}
+	if filter.FromYear > 0 {
+		query.Set("fromYear", strconv.Itoa(filter.FromYear))
+	}
+	if filter.ToYear > 0 {
+		query.Set("toYear", strconv.Itoa(filter.ToYear))
+	}
+	connection.setMusicFolder(query)
 	requestUrl := connection.Host + "/rest/getRandomSongs" + "?" + query.Encode()
 	resp, err := connection.getResponse("GetRandomSongs", requestUrl)
 	if err != nil {
@@ -215,6 +856,53 @@ func (connection *SubsonicConnection) GetRandomSongs() (*SubsonicResponse, error
 	return resp, nil
 }
 
+// GetSimilarSongs returns songs similar to a song, album or artist folder,
+// using last.fm data on the server
+func (connection *SubsonicConnection) GetSimilarSongs(id string, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getSimilarSongs" + "?" + query.Encode()
+	return connection.getResponse("GetSimilarSongs", requestUrl)
+}
+
+// GetSimilarSongs2 is GetSimilarSongs for artists found by their ID3 tags
+func (connection *SubsonicConnection) GetSimilarSongs2(artistId string, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", artistId)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getSimilarSongs2" + "?" + query.Encode()
+	return connection.getResponse("GetSimilarSongs2", requestUrl)
+}
+
+// GetTopSongs returns the most popular songs of an artist, by name
+func (connection *SubsonicConnection) GetTopSongs(artist string, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("artist", artist)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getTopSongs" + "?" + query.Encode()
+	return connection.getResponse("GetTopSongs", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetGenres() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getGenres" + "?" + query.Encode()
+	return connection.getResponse("GetGenres", requestUrl)
+}
+
+// number of songs requested for each page of a genre
+const songsByGenrePageSize = 50
+
+func (connection *SubsonicConnection) GetSongsByGenre(genre string, offset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("genre", genre)
+	query.Set("count", strconv.Itoa(songsByGenrePageSize))
+	query.Set("offset", strconv.Itoa(offset))
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getSongsByGenre" + "?" + query.Encode()
+	return connection.getResponse("GetSongsByGenre", requestUrl)
+}
+
 func (connection *SubsonicConnection) ScrobbleSubmission(id string, isSubmission bool) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	query.Set("id", id)
@@ -231,9 +919,11 @@ func (connection *SubsonicConnection) ScrobbleSubmission(id string, isSubmission
 	return resp, nil
 }
 
+// GetStarred returns everything starred, in every music folder, so stars
+// show up wherever the artists, albums and songs are listed
 func (connection *SubsonicConnection) GetStarred() (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-	requestUrl := connection.Host + "/rest/getStarred" + "?" + query.Encode()
+	requestUrl := connection.Host + "/rest/getStarred2" + "?" + query.Encode()
 	resp, err := connection.getResponse("GetStarred", requestUrl)
 	if err != nil {
 		return resp, err
@@ -242,8 +932,22 @@ func (connection *SubsonicConnection) GetStarred() (*SubsonicResponse, error) {
 }
 
 func (connection *SubsonicConnection) ToggleStar(id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
+	return connection.toggleStar("id", id, starredItems)
+}
+
+// ToggleAlbumStar stars or unstars an album found by its ID3 tags
+func (connection *SubsonicConnection) ToggleAlbumStar(id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
+	return connection.toggleStar("albumId", id, starredItems)
+}
+
+// ToggleArtistStar stars or unstars an artist found by its ID3 tags
+func (connection *SubsonicConnection) ToggleArtistStar(id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
+	return connection.toggleStar("artistId", id, starredItems)
+}
+
+func (connection *SubsonicConnection) toggleStar(idParam string, id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-	query.Set("id",id)
+	query.Set(idParam, id)
 
 	_, ok := starredItems[id]
 	var action = "star"
@@ -253,23 +957,63 @@ func (connection *SubsonicConnection) ToggleStar(id string, starredItems map[str
 	}
 
 	requestUrl := connection.Host + "/rest/" + action + "?" + query.Encode()
-	resp, err := connection.getResponse("ToggleStar", requestUrl)
-	if err != nil {
-		if (ok) {
-			delete(starredItems, id)
-		} else {
-			starredItems[id] = struct{}{}
-		}
-		return resp, err
+	return connection.getResponse("ToggleStar", requestUrl)
+}
+
+// SetRating rates a song, album or artist from 1 to 5 stars. A rating of 0
+// removes the rating.
+func (connection *SubsonicConnection) SetRating(id string, rating int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("rating", strconv.Itoa(rating))
+	requestUrl := connection.Host + "/rest/setRating" + "?" + query.Encode()
+	return connection.getResponse("SetRating", requestUrl)
+}
+
+// number of results requested for each section of a search
+const searchPageSize = 20
+
+// Search3 searches the whole library for artists, albums and songs. Each
+// section is paged separately by its offset.
+func (connection *SubsonicConnection) Search3(searchQuery string, artistOffset int, albumOffset int, songOffset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("query", searchQuery)
+	query.Set("artistCount", strconv.Itoa(searchPageSize))
+	query.Set("artistOffset", strconv.Itoa(artistOffset))
+	query.Set("albumCount", strconv.Itoa(searchPageSize))
+	query.Set("albumOffset", strconv.Itoa(albumOffset))
+	query.Set("songCount", strconv.Itoa(searchPageSize))
+	query.Set("songOffset", strconv.Itoa(songOffset))
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/search3" + "?" + query.Encode()
+	return connection.getResponse("Search3", requestUrl)
+}
+
+// directoryTTL returns how long cached directories are used for
+func (connection *SubsonicConnection) directoryTTL() time.Duration {
+	if connection.Cache == nil {
+		return 0
 	}
-	return resp, nil
+	return connection.Cache.DirectoryTTL
 }
 
+// GetPlaylists returns the playlists with all of their songs. They are cached
+// on disk, but not in memory, so they are fetched again once the cached ones
+// are too old.
 func (connection *SubsonicConnection) GetPlaylists() (*SubsonicResponse, error) {
+	if connection.Cache != nil {
+		if cachedResponse, present := connection.Cache.Load("playlists", connection.Cache.PlaylistTTL); present {
+			return cachedResponse, nil
+		}
+	}
+
 	query := defaultQuery(connection)
 	requestUrl := connection.Host + "/rest/getPlaylists" + "?" + query.Encode()
 	resp, err := connection.getResponse("GetPlaylists", requestUrl)
 	if err != nil {
+		if staleResponse, present := connection.staleCached("playlists", err); present {
+			return staleResponse, nil
+		}
 		return resp, err
 	}
 
@@ -289,9 +1033,22 @@ func (connection *SubsonicConnection) GetPlaylists() (*SubsonicResponse, error)
 		playlist.Entries = response.Playlist.Entries
 	}
 
+	if connection.Cache != nil {
+		if err := connection.Cache.Store("playlists", resp); err != nil {
+			connection.Logger.Printf("GetPlaylists: Store -- %s", err.Error())
+		}
+	}
+
 	return resp, nil
 }
 
+// forgetPlaylists drops the cached playlists after they are changed
+func (connection *SubsonicConnection) forgetPlaylists() {
+	if connection.Cache != nil {
+		connection.Cache.Remove("playlists")
+	}
+}
+
 func (connection *SubsonicConnection) GetPlaylist(id string) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	query.Set("id", id)
@@ -304,11 +1061,311 @@ func (connection *SubsonicConnection) CreatePlaylist(name string) (*SubsonicResp
 	query := defaultQuery(connection)
 	query.Set("name", name)
 	requestUrl := connection.Host + "/rest/createPlaylist" + "?" + query.Encode()
+	connection.forgetPlaylists()
 	return connection.getResponse("GetPlaylist", requestUrl)
 }
 
+// GetUser returns the details of a user, including the roles that decide
+// what they are allowed to change on the server
+func (connection *SubsonicConnection) GetUser(username string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("username", username)
+	requestUrl := connection.Host + "/rest/getUser" + "?" + query.Encode()
+	return connection.getResponse("GetUser", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetInternetRadioStations() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getInternetRadioStations" + "?" + query.Encode()
+	return connection.getResponse("GetInternetRadioStations", requestUrl)
+}
+
+func (connection *SubsonicConnection) CreateInternetRadioStation(name string, streamUrl string, homePageUrl string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("name", name)
+	query.Set("streamUrl", streamUrl)
+	if homePageUrl != "" {
+		query.Set("homepageUrl", homePageUrl)
+	}
+	requestUrl := connection.Host + "/rest/createInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponse("CreateInternetRadioStation", requestUrl)
+}
+
+func (connection *SubsonicConnection) UpdateInternetRadioStation(id string, name string, streamUrl string, homePageUrl string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("name", name)
+	query.Set("streamUrl", streamUrl)
+	if homePageUrl != "" {
+		query.Set("homepageUrl", homePageUrl)
+	}
+	requestUrl := connection.Host + "/rest/updateInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponse("UpdateInternetRadioStation", requestUrl)
+}
+
+func (connection *SubsonicConnection) DeleteInternetRadioStation(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponse("DeleteInternetRadioStation", requestUrl)
+}
+
+// GetPodcasts returns the podcast channels the server is subscribed to,
+// along with their episodes
+func (connection *SubsonicConnection) GetPodcasts() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("includeEpisodes", "true")
+	requestUrl := connection.Host + "/rest/getPodcasts" + "?" + query.Encode()
+	return connection.getResponse("GetPodcasts", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetNewestPodcasts(count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getNewestPodcasts" + "?" + query.Encode()
+	return connection.getResponse("GetNewestPodcasts", requestUrl)
+}
+
+// DownloadPodcastEpisode asks the server to download an episode, so it can be
+// streamed
+func (connection *SubsonicConnection) DownloadPodcastEpisode(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/downloadPodcastEpisode" + "?" + query.Encode()
+	return connection.getResponse("DownloadPodcastEpisode", requestUrl)
+}
+
+func (connection *SubsonicConnection) CreatePodcastChannel(url string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("url", url)
+	requestUrl := connection.Host + "/rest/createPodcastChannel" + "?" + query.Encode()
+	return connection.getResponse("CreatePodcastChannel", requestUrl)
+}
+
+func (connection *SubsonicConnection) DeletePodcastChannel(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deletePodcastChannel" + "?" + query.Encode()
+	return connection.getResponse("DeletePodcastChannel", requestUrl)
+}
+
+// SavePlayQueue stores the play queue on the server, so it can be picked up
+// again later or from another client. position is in milliseconds into the
+// current song.
+func (connection *SubsonicConnection) SavePlayQueue(ids []string, current string, position int64) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	if current != "" {
+		query.Set("current", current)
+		query.Set("position", strconv.FormatInt(position, 10))
+	}
+	requestUrl := connection.Host + "/rest/savePlayQueue" + "?" + query.Encode()
+	return connection.getResponse("SavePlayQueue", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetPlayQueue() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getPlayQueue" + "?" + query.Encode()
+	return connection.getResponse("GetPlayQueue", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetBookmarks() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getBookmarks" + "?" + query.Encode()
+	return connection.getResponse("GetBookmarks", requestUrl)
+}
+
+// CreateBookmark saves a position, in milliseconds, in a song. The server
+// keeps a single bookmark per song, so this replaces any earlier one.
+func (connection *SubsonicConnection) CreateBookmark(id string, position int64, comment string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("position", strconv.FormatInt(position, 10))
+	if comment != "" {
+		query.Set("comment", comment)
+	}
+	requestUrl := connection.Host + "/rest/createBookmark" + "?" + query.Encode()
+	return connection.getResponse("CreateBookmark", requestUrl)
+}
+
+func (connection *SubsonicConnection) DeleteBookmark(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteBookmark" + "?" + query.Encode()
+	return connection.getResponse("DeleteBookmark", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetShares() (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getShares" + "?" + query.Encode()
+	return connection.getResponse("GetShares", requestUrl)
+}
+
+// CreateShare creates a public link to the given songs, albums or playlists.
+// A zero expires creates a link that never expires.
+func (connection *SubsonicConnection) CreateShare(ids []string, description string, expires time.Time) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	if description != "" {
+		query.Set("description", description)
+	}
+	if !expires.IsZero() {
+		query.Set("expires", strconv.FormatInt(expires.UnixNano()/int64(time.Millisecond), 10))
+	}
+	requestUrl := connection.Host + "/rest/createShare" + "?" + query.Encode()
+	return connection.getResponse("CreateShare", requestUrl)
+}
+
+func (connection *SubsonicConnection) DeleteShare(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteShare" + "?" + query.Encode()
+	return connection.getResponse("DeleteShare", requestUrl)
+}
+
+// GetCoverArt returns the cover art image with the given id, scaled by the
+// server to size pixels. A size of 0 returns the original image.
+func (connection *SubsonicConnection) GetCoverArt(id string, size int) ([]byte, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	if size > 0 {
+		query.Set("size", strconv.Itoa(size))
+	}
+	requestUrl := connection.Host + "/rest/getCoverArt" + "?" + query.Encode()
+
+	res, err := connection.request(requestUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// errors come back as a regular response rather than an image
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") || res.StatusCode != http.StatusOK {
+		_, err := decodeResponse(res, data)
+		if err == nil {
+			err = &SubsonicAPIError{HTTPStatus: res.StatusCode, Message: "no image returned"}
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (connection *SubsonicConnection) GetLyricsBySongId(id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getLyricsBySongId" + "?" + query.Encode()
+	return connection.getResponse("GetLyricsBySongId", requestUrl)
+}
+
+func (connection *SubsonicConnection) GetLyrics(artist string, title string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("artist", artist)
+	query.Set("title", title)
+	requestUrl := connection.Host + "/rest/getLyrics" + "?" + query.Encode()
+	return connection.getResponse("GetLyrics", requestUrl)
+}
+
+// GetSongLyrics returns the lyrics of a song, preferring synced lyrics from
+// the OpenSubsonic getLyricsBySongId, and falling back to matching the artist
+// and title with getLyrics on servers without the songLyrics extension. nil
+// is returned if the server has no lyrics for the song.
+func (connection *SubsonicConnection) GetSongLyrics(id string, artist string, title string) (*SubsonicStructuredLyrics, error) {
+	if connection.HasExtension(ExtensionSongLyrics) {
+		response, err := connection.GetLyricsBySongId(id)
+		if err == nil {
+			var found *SubsonicStructuredLyrics
+			for i, lyrics := range response.LyricsList.StructuredLyrics {
+				if len(lyrics.Lines) == 0 {
+					continue
+				}
+				if found == nil || (lyrics.Synced && !found.Synced) {
+					found = &response.LyricsList.StructuredLyrics[i]
+				}
+			}
+			if found != nil {
+				return found, nil
+			}
+		}
+	}
+
+	response, err := connection.GetLyrics(artist, title)
+	if err != nil {
+		return nil, err
+	}
+	return plainLyrics(response.Lyrics), nil
+}
+
+// plainLyrics splits the lyrics returned by getLyrics into unsynced lines,
+// or returns nil if there are none
+func plainLyrics(plain SubsonicLyrics) *SubsonicStructuredLyrics {
+	text := strings.TrimSpace(strings.ReplaceAll(plain.Value, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+
+	lyrics := SubsonicStructuredLyrics{
+		DisplayArtist: plain.Artist,
+		DisplayTitle:  plain.Title,
+	}
+	for _, line := range strings.Split(text, "\n") {
+		lyrics.Lines = append(lyrics.Lines, SubsonicLyricsLine{Value: line})
+	}
+	return &lyrics
+}
+
+// request makes an API request. Servers with the formPost extension are sent
+// the parameters in a form body, which keeps the credentials out of server and
+// proxy logs and lifts the limit on the length of the url. Idempotent requests
+// that fail are retried with backoff.
+func (connection *SubsonicConnection) request(requestUrl string) (*http.Response, error) {
+	return connection.requestWith(connection.Client, requestUrl)
+}
+
+// requestWith is request, made with client
+func (connection *SubsonicConnection) requestWith(client *http.Client, requestUrl string) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(requestUrl) {
+		retries = connection.Retries
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := connection.send(client, requestUrl)
+		if attempt == retries || !isRetryable(res, err) {
+			return res, err
+		}
+		if err == nil {
+			res.Body.Close()
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (connection *SubsonicConnection) send(client *http.Client, requestUrl string) (*http.Response, error) {
+	if !connection.HasExtension(ExtensionFormPost) {
+		return client.Get(requestUrl)
+	}
+
+	endpoint, rawQuery := requestUrl, ""
+	if i := strings.Index(requestUrl, "?"); i >= 0 {
+		endpoint, rawQuery = requestUrl[:i], requestUrl[i+1:]
+	}
+	return client.Post(endpoint, "application/x-www-form-urlencoded", strings.NewReader(rawQuery))
+}
+
 func (connection *SubsonicConnection) getResponse(caller, requestUrl string) (*SubsonicResponse, error) {
-	res, err := http.Get(requestUrl)
+	res, err := connection.request(requestUrl)
 
 	if err != nil {
 		return nil, err
@@ -318,27 +1375,48 @@ func (connection *SubsonicConnection) getResponse(caller, requestUrl string) (*S
 		defer res.Body.Close()
 	}
 
-	responseBody, readErr := ioutil.ReadAll(res.Body)
+	responseBody, err := ioutil.ReadAll(res.Body)
 
-	if readErr != nil {
+	if err != nil {
 		return nil, err
 	}
 
+	return decodeResponse(res, responseBody)
+}
+
+// decodeResponse decodes the body of an API response, returning a
+// SubsonicAPIError if the request failed
+func decodeResponse(res *http.Response, body []byte) (*SubsonicResponse, error) {
 	var decodedBody responseWrapper
-	err = json.Unmarshal(responseBody, &decodedBody)
+	err := json.Unmarshal(body, &decodedBody)
 
 	if err != nil {
+		// proxies and servers without an endpoint answer with pages of their
+		// own, which are only worth reporting by their status
+		if res.StatusCode != http.StatusOK {
+			return nil, &SubsonicAPIError{HTTPStatus: res.StatusCode}
+		}
 		return nil, err
 	}
 
-	return &decodedBody.Response, nil
+	response := &decodedBody.Response
+	if response.Status == "failed" || res.StatusCode != http.StatusOK {
+		return nil, &SubsonicAPIError{
+			Code:       response.Error.Code,
+			Message:    response.Error.Message,
+			HTTPStatus: res.StatusCode,
+		}
+	}
+
+	return response, nil
 }
 
 func (connection *SubsonicConnection) DeletePlaylist(id string) error {
 	query := defaultQuery(connection)
 	query.Set("id", id)
 	requestUrl := connection.Host + "/rest/deletePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	connection.forgetPlaylists()
+	_, err := connection.getResponse("DeletePlaylist", requestUrl)
 	return err
 }
 
@@ -347,7 +1425,8 @@ func (connection *SubsonicConnection) AddSongToPlaylist(playlistId string, songI
 	query.Set("playlistId", playlistId)
 	query.Set("songIdToAdd", songId)
 	requestUrl := connection.Host + "/rest/updatePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	connection.forgetPlaylists()
+	_, err := connection.getResponse("AddSongToPlaylist", requestUrl)
 	return err
 }
 
@@ -356,19 +1435,78 @@ func (connection *SubsonicConnection) RemoveSongFromPlaylist(playlistId string,
 	query.Set("playlistId", playlistId)
 	query.Set("songIndexToRemove", strconv.Itoa(songIndex))
 	requestUrl := connection.Host + "/rest/updatePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	connection.forgetPlaylists()
+	_, err := connection.getResponse("RemoveSongFromPlaylist", requestUrl)
 	return err
 }
 
 // note that this function does not make a request, it just formats the play url
-// to pass to mpv
+// to pass to mpv. Downloaded songs are played from the track cache instead.
 func (connection *SubsonicConnection) GetPlayUrl(entity *SubsonicEntity) string {
 	// we don't want to call stream on a directory
 	if entity.IsDirectory {
 		return ""
 	}
 
+	if connection.Tracks != nil {
+		if path, ok := connection.Tracks.Path(entity.Id); ok {
+			return path
+		}
+	}
+	return connection.streamUrl(entity.Id)
+}
+
+func (connection *SubsonicConnection) streamUrl(id string) string {
 	query := defaultQuery(connection)
-	query.Set("id", entity.Id)
+	query.Set("id", id)
+	if connection.MaxBitRate > 0 {
+		query.Set("maxBitRate", strconv.Itoa(connection.MaxBitRate))
+	}
+	if connection.Format != "" {
+		query.Set("format", connection.Format)
+	}
+	// transcoded streams have no length of their own, which keeps mpv from
+	// seeking in them
+	if connection.MaxBitRate > 0 || (connection.Format != "" && connection.Format != "raw") {
+		query.Set("estimateContentLength", "true")
+	}
 	return connection.Host + "/rest/stream" + "?" + query.Encode()
 }
+
+// GetDownloadUrl returns the url of a song's original file or, if transcode
+// is set, of a stream transcoded with the streaming settings
+func (connection *SubsonicConnection) GetDownloadUrl(id string, transcode bool) string {
+	if transcode {
+		return connection.streamUrl(id)
+	}
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	return connection.Host + "/rest/download" + "?" + query.Encode()
+}
+
+// Download returns the contents of a song from a download url. The caller
+// has to close it.
+func (connection *SubsonicConnection) Download(requestUrl string) (io.ReadCloser, error) {
+	client := connection.DownloadClient
+	if client == nil {
+		client = connection.Client
+	}
+	res, err := connection.requestWith(client, requestUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	// errors come back as a regular response rather than the song
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") || res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := decodeResponse(res, body); err != nil {
+			return nil, err
+		}
+		return nil, &SubsonicAPIError{HTTPStatus: res.StatusCode, Message: "no song returned"}
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrentCallers is how many goroutines ask for the same response at once
+const concurrentCallers = 8
+
+// countingServer answers every request with body, holding each one for a
+// while so concurrent callers pile up, and records how many it was answering
+// at once
+type countingServer struct {
+	*httptest.Server
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requests    int
+}
+
+func newCountingServer(t *testing.T, body string) *countingServer {
+	server := &countingServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		server.requests++
+		server.inFlight++
+		if server.inFlight > server.maxInFlight {
+			server.maxInFlight = server.inFlight
+		}
+		server.lock.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		server.lock.Lock()
+		server.inFlight--
+		server.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestConnection(server *countingServer) *SubsonicConnection {
+	return &SubsonicConnection{
+		Username:       "admin",
+		Password:       "password",
+		Host:           server.URL,
+		Client:         server.Client(),
+		Logger:         Logger{prints: make(chan string, 100)},
+		directoryCache: make(map[string]SubsonicResponse),
+	}
+}
+
+// callConcurrently calls get from concurrentCallers goroutines at once, and
+// checks each response with check
+func callConcurrently(t *testing.T, get func() (*SubsonicResponse, error), check func(*SubsonicResponse)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < concurrentCallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			response, err := get()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			check(response)
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+const directoryBody = `{"subsonic-response": {"status": "ok", "version": "1.15.0",
+	"directory": {"id": "1", "name": "Album", "child": [
+		{"id": "4", "title": "Four", "track": 4},
+		{"id": "3", "title": "Three", "track": 3},
+		{"id": "2", "title": "Two", "track": 2},
+		{"id": "1", "title": "One", "track": 1}
+	]}}}`
+
+func TestGetMusicDirectoryConcurrently(t *testing.T) {
+	server := newCountingServer(t, directoryBody)
+	connection := newTestConnection(server)
+
+	callConcurrently(t, func() (*SubsonicResponse, error) {
+		return connection.GetMusicDirectory("1")
+	}, func(response *SubsonicResponse) {
+		// the GUI sorts the songs of directories in place
+		entities := response.Directory.Entities
+		sort.Sort(entities)
+		if len(entities) != 4 || entities[0].Id != "1" || entities[3].Id != "4" {
+			t.Errorf("unexpected songs %v", entities)
+		}
+	})
+
+	if server.maxInFlight != 1 {
+		t.Errorf("%d requests were in flight at once, want 1", server.maxInFlight)
+	}
+	if server.requests != 1 {
+		t.Errorf("%d requests were made, want 1", server.requests)
+	}
+
+	// the cached response has to be left as the server sent it
+	response, err := connection.GetMusicDirectory("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := response.Directory.Entities[0].Id; first != "4" {
+		t.Errorf("cached directory starts with %s, want 4", first)
+	}
+}
+
+const indexesBody = `{"subsonic-response": {"status": "ok", "version": "1.15.0",
+	"indexes": {"lastModified": 1, "index": [
+		{"name": "B", "artist": [{"id": "2", "name": "Beta"}]},
+		{"name": "A", "artist": [{"id": "1", "name": "Alpha"}]}
+	]}}}`
+
+func TestGetIndexesConcurrently(t *testing.T) {
+	server := newCountingServer(t, indexesBody)
+	connection := newTestConnection(server)
+
+	callConcurrently(t, connection.GetIndexes, func(response *SubsonicResponse) {
+		index := response.Indexes.Index
+		sort.Slice(index, func(i, j int) bool {
+			return index[i].Name < index[j].Name
+		})
+		if len(index) != 2 || index[0].Name != "A" || index[0].Artists[0].Name != "Alpha" {
+			t.Errorf("unexpected indexes %v", index)
+		}
+	})
+
+	if server.maxInFlight != 1 {
+		t.Errorf("%d requests were in flight at once, want 1", server.maxInFlight)
+	}
+}
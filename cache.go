@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetadataCache keeps responses on disk between sessions, so browsing
+// doesn't have to fetch everything from the server again on every start
+type MetadataCache struct {
+	dir string
+	// how long cached directories and playlists are used before they are
+	// fetched again. Indexes are checked with the server every time instead.
+	DirectoryTTL time.Duration
+	PlaylistTTL  time.Duration
+}
+
+type cacheEntry struct {
+	Fetched  time.Time        `json:"fetched"`
+	Response SubsonicResponse `json:"response"`
+}
+
+// NewMetadataCache returns a cache for the responses of a server, kept apart
+// from the caches of any other servers and users
+func NewMetadataCache(host string, username string) (*MetadataCache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir = filepath.Join(dir, "metadata", serverKey(host, username))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &MetadataCache{dir: dir}, nil
+}
+
+func (cache *MetadataCache) path(key string) string {
+	return filepath.Join(cache.dir, fmt.Sprintf("%x.json", md5.Sum([]byte(key))))
+}
+
+// Load returns the response cached under key, unless it's older than ttl. A
+// ttl of 0 never expires.
+func (cache *MetadataCache) Load(key string, ttl time.Duration) (*SubsonicResponse, bool) {
+	data, err := ioutil.ReadFile(cache.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if ttl > 0 && time.Since(entry.Fetched) > ttl {
+		return nil, false
+	}
+	return &entry.Response, true
+}
+
+func (cache *MetadataCache) Store(key string, response *SubsonicResponse) error {
+	data, err := json.Marshal(cacheEntry{Fetched: time.Now(), Response: *response})
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so neither a crash nor another
+	// goroutine storing the same key ever leaves half an entry
+	file, err := ioutil.TempFile(cache.dir, "store-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), cache.path(key))
+}
+
+func (cache *MetadataCache) Remove(key string) {
+	os.Remove(cache.path(key))
+}
+
+// Clear removes everything from the cache
+func (cache *MetadataCache) Clear() error {
+	entries, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		// entries may be removed by another goroutine at the same time
+		err := os.Remove(filepath.Join(cache.dir, entry.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
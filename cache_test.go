@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMetadataCache(t *testing.T) *MetadataCache {
+	return &MetadataCache{dir: t.TempDir()}
+}
+
+// storeFetched caches response under key as if it had been fetched age ago
+func storeFetched(t *testing.T, cache *MetadataCache, key string, response SubsonicResponse, age time.Duration) {
+	data, err := json.Marshal(cacheEntry{Fetched: time.Now().Add(-age), Response: response})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cache.path(key), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataCacheStore(t *testing.T) {
+	cache := newTestMetadataCache(t)
+	if _, present := cache.Load("directory 1", 0); present {
+		t.Error("loaded an entry that was never stored")
+	}
+
+	response := SubsonicResponse{Status: "ok", Directory: SubsonicDirectory{Id: "1", Name: "Album"}}
+	if err := cache.Store("directory 1", &response); err != nil {
+		t.Fatal(err)
+	}
+	loaded, present := cache.Load("directory 1", time.Hour)
+	if !present || loaded.Directory.Name != "Album" {
+		t.Errorf("loaded %v, %v, want the stored directory", loaded, present)
+	}
+	if _, present := cache.Load("directory 2", 0); present {
+		t.Error("loaded an entry under another key")
+	}
+
+	cache.Remove("directory 1")
+	if _, present := cache.Load("directory 1", 0); present {
+		t.Error("loaded a removed entry")
+	}
+}
+
+func TestMetadataCacheExpiry(t *testing.T) {
+	cache := newTestMetadataCache(t)
+	storeFetched(t, cache, "playlists", SubsonicResponse{Status: "ok"}, 2*time.Hour)
+
+	if _, present := cache.Load("playlists", time.Hour); present {
+		t.Error("loaded an entry older than its ttl")
+	}
+	if _, present := cache.Load("playlists", 3*time.Hour); !present {
+		t.Error("didn't load an entry younger than its ttl")
+	}
+	// indexes are kept until the server says they changed
+	if _, present := cache.Load("playlists", 0); !present {
+		t.Error("didn't load an entry without a ttl")
+	}
+}
+
+func TestMetadataCacheClear(t *testing.T) {
+	cache := newTestMetadataCache(t)
+	for _, key := range []string{"indexes", "playlists"} {
+		if err := cache.Store(key, &SubsonicResponse{Status: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"indexes", "playlists"} {
+		if _, present := cache.Load(key, 0); present {
+			t.Errorf("loaded %s after clearing the cache", key)
+		}
+	}
+}
+
+func TestMetadataCacheStoreConcurrently(t *testing.T) {
+	cache := newTestMetadataCache(t)
+	big := SubsonicResponse{Status: "ok", Directory: SubsonicDirectory{Id: "1"}}
+	for i := 0; i < 1000; i++ {
+		big.Directory.Entities = append(big.Directory.Entities, SubsonicEntity{Id: "song", Title: "A song with a long enough title"})
+	}
+	small := SubsonicResponse{Status: "ok", Directory: SubsonicDirectory{Id: "1"}}
+
+	// loads in between stores see one whole entry or the other, never a mix
+	// or half of one
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentCallers; i++ {
+		wg.Add(1)
+		go func(response *SubsonicResponse) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := cache.Store("directory 1", response); err != nil {
+					t.Error(err)
+					return
+				}
+				loaded, present := cache.Load("directory 1", 0)
+				if !present {
+					t.Error("stored entry couldn't be loaded")
+					return
+				}
+				if count := len(loaded.Directory.Entities); count != 0 && count != 1000 {
+					t.Errorf("loaded a directory of %d songs, want 0 or 1000", count)
+					return
+				}
+			}
+		}([]*SubsonicResponse{&big, &small}[i%2])
+	}
+	wg.Wait()
+
+	// nothing is left of the temporary files
+	entries, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d files, want 1", len(entries))
+	}
+}
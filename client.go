@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ClientConfig holds the connection settings shared by the API client and
+// mpv, which streams from the same server
+type ClientConfig struct {
+	Timeout time.Duration
+	// number of times an idempotent request is retried after failing
+	Retries int
+	// PEM bundle of certificate authorities trusted in addition to the
+	// system ones, for servers with self-signed certificates
+	CAFile             string
+	InsecureSkipVerify bool
+	// client certificate and key, for servers that require one
+	CertFile string
+	KeyFile  string
+	// http, https or socks5 proxy url. Without one the proxy environment
+	// variables are used.
+	Proxy string
+}
+
+// delay before the first retry, doubled for every retry after it
+const retryBackoff = 500 * time.Millisecond
+
+// NewHTTPClient returns a client for the API configured from config
+func NewHTTPClient(config ClientConfig) (*http.Client, error) {
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}, nil
+}
+
+// NewDownloadClient returns a client for downloading songs configured from
+// config. A long song on a slow connection can take longer than the timeout
+// to download, so the timeout only applies to connecting and to waiting for
+// the server to start answering.
+func NewDownloadClient(config ClientConfig) (*http.Client, error) {
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = config.Timeout
+	transport.ResponseHeaderTimeout = config.Timeout
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTransport returns a transport with the TLS and proxy settings of config
+func newTransport(config ClientConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" {
+		keyFile := config.KeyFile
+		if keyFile == "" {
+			// the key may be in the same file as the certificate
+			keyFile = config.CertFile
+		}
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if config.Proxy != "" {
+		proxyUrl, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyUrl.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+	return transport, nil
+}
+
+// isIdempotent reports whether the API endpoint of a url can safely be
+// requested again, because it only reads from the server
+func isIdempotent(requestUrl string) bool {
+	endpoint := requestUrl
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	endpoint = strings.TrimSuffix(path.Base(endpoint), ".view")
+
+	for _, prefix := range []string{"get", "search", "ping", "download", "stream"} {
+		if strings.HasPrefix(endpoint, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryable reports whether a request that got res or err may succeed if
+// it's made again
+func isRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
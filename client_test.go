@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://music.example/rest/getIndexes.view?u=admin&f=json", true},
+		{"https://music.example/rest/getMusicDirectory?id=1", true},
+		{"https://music.example/rest/search3.view?query=a", true},
+		{"https://music.example/rest/ping.view", true},
+		{"https://music.example/rest/download.view?id=1", true},
+		{"https://music.example/rest/stream.view?id=1&format=raw", true},
+		{"https://music.example/rest/star.view?id=1", false},
+		{"https://music.example/rest/scrobble.view?id=1", false},
+		{"https://music.example/rest/savePlayQueue.view?id=1", false},
+		{"https://music.example/rest/createShare.view?id=1", false},
+		// only the endpoint is looked at, not the parameters
+		{"https://music.example/rest/setRating.view?id=getIndexes", false},
+	}
+
+	for _, test := range tests {
+		if got := isIdempotent(test.url); got != test.want {
+			t.Errorf("isIdempotent(%q) = %v, want %v", test.url, got, test.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"connection error", 0, errors.New("connection reset"), true},
+		{"ok", http.StatusOK, nil, false},
+		{"not found", http.StatusNotFound, nil, false},
+		{"unauthorized", http.StatusUnauthorized, nil, false},
+		{"too many requests", http.StatusTooManyRequests, nil, true},
+		{"server error", http.StatusInternalServerError, nil, true},
+		{"bad gateway", http.StatusBadGateway, nil, true},
+		{"unavailable", http.StatusServiceUnavailable, nil, true},
+	}
+
+	for _, test := range tests {
+		var res *http.Response
+		if test.err == nil {
+			res = &http.Response{StatusCode: test.status}
+		}
+		if got := isRetryable(res, test.err); got != test.want {
+			t.Errorf("%s: isRetryable = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackCache keeps downloaded songs on disk, so they can be played without a
+// connection to the server. Once it grows past MaxSize, the songs played
+// least recently are removed, except for the ones pinned for offline use.
+type TrackCache struct {
+	dir string
+	// in bytes, 0 for no limit
+	MaxSize int64
+
+	lock   sync.Mutex
+	pinned map[string]struct{}
+	// songs downloaded transcoded, which don't do for an original download
+	transcoded map[string]struct{}
+}
+
+const (
+	pinnedFile     = "pinned.json"
+	transcodedFile = "transcoded.json"
+)
+
+// NewTrackCache returns the track cache of a server, kept apart from the
+// caches of any other servers and users
+func NewTrackCache(host string, username string) (*TrackCache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir = filepath.Join(dir, "tracks", serverKey(host, username))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	cache := &TrackCache{dir: dir}
+	if cache.pinned, err = loadIds(filepath.Join(dir, pinnedFile)); err != nil {
+		return nil, err
+	}
+	if cache.transcoded, err = loadIds(filepath.Join(dir, transcodedFile)); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// loadIds reads a set of song ids saved with saveIds, which is empty if it
+// hasn't been saved yet
+func loadIds(path string) (map[string]struct{}, error) {
+	ids := make(map[string]struct{})
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ids, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, id := range list {
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
+
+func saveIds(path string, ids map[string]struct{}) error {
+	list := make([]string, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func (cache *TrackCache) path(id string) string {
+	return filepath.Join(cache.dir, fmt.Sprintf("%x", md5.Sum([]byte(id))))
+}
+
+// Has reports whether a song has been downloaded
+func (cache *TrackCache) Has(id string) bool {
+	_, err := os.Stat(cache.path(id))
+	return err == nil
+}
+
+// HasDownload reports whether a song has been downloaded in a way that does
+// for a download with or without transcoding. A transcoded copy only does for
+// another transcoded one.
+func (cache *TrackCache) HasDownload(id string, transcode bool) bool {
+	if !cache.Has(id) {
+		return false
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	_, transcoded := cache.transcoded[id]
+	return transcode || !transcoded
+}
+
+// Path returns the file a song was downloaded to, if it has been
+func (cache *TrackCache) Path(id string) (string, bool) {
+	path := cache.path(id)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Touch marks a song as just played, so it is the last to be removed
+func (cache *TrackCache) Touch(id string) {
+	now := time.Now()
+	os.Chtimes(cache.path(id), now, now)
+}
+
+// Store saves a song read from r in the cache, replacing any earlier
+// download of it. It may grow past MaxSize until Evict is called.
+func (cache *TrackCache) Store(id string, r io.Reader, transcoded bool) error {
+	// download to a temporary file, so a song cut short is never played
+	file, err := ioutil.TempFile(cache.dir, "download-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), cache.path(id)); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if _, ok := cache.transcoded[id]; ok == transcoded {
+		return nil
+	}
+	if transcoded {
+		cache.transcoded[id] = struct{}{}
+	} else {
+		delete(cache.transcoded, id)
+	}
+	return saveIds(filepath.Join(cache.dir, transcodedFile), cache.transcoded)
+}
+
+// Evict removes the songs played least recently until the cache fits in
+// MaxSize again. Pinned songs and the songs in keep, such as those queued to
+// be played from the cache, are never removed.
+func (cache *TrackCache) Evict(keep []string) error {
+	if cache.MaxSize <= 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		return err
+	}
+
+	cache.lock.Lock()
+	protected := make(map[string]struct{})
+	for _, id := range keep {
+		protected[cache.path(id)] = struct{}{}
+	}
+	for id := range cache.pinned {
+		protected[cache.path(id)] = struct{}{}
+	}
+	cache.lock.Unlock()
+
+	var size int64
+	var candidates []os.FileInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == pinnedFile || name == transcodedFile || strings.HasPrefix(name, "download-") {
+			continue
+		}
+		size += entry.Size()
+		if _, ok := protected[filepath.Join(cache.dir, name)]; !ok {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ModTime().Before(candidates[j].ModTime())
+	})
+	for _, entry := range candidates {
+		if size <= cache.MaxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(cache.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= entry.Size()
+	}
+	return nil
+}
+
+// IsPinned reports whether a song is kept for offline use
+func (cache *TrackCache) IsPinned(id string) bool {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	_, ok := cache.pinned[id]
+	return ok
+}
+
+// Pin keeps songs for offline use, however full the cache gets
+func (cache *TrackCache) Pin(ids []string) error {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for _, id := range ids {
+		cache.pinned[id] = struct{}{}
+	}
+	return cache.savePinned()
+}
+
+// Unpin lets songs be removed from the cache again, once it gets full
+func (cache *TrackCache) Unpin(ids []string) error {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for _, id := range ids {
+		delete(cache.pinned, id)
+	}
+	return cache.savePinned()
+}
+
+func (cache *TrackCache) savePinned() error {
+	return saveIds(filepath.Join(cache.dir, pinnedFile), cache.pinned)
+}
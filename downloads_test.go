@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTrackCache(t *testing.T) *TrackCache {
+	return &TrackCache{dir: t.TempDir(), pinned: make(map[string]struct{}), transcoded: make(map[string]struct{})}
+}
+
+// storeTrack stores a song of size bytes, played age ago
+func storeTrack(t *testing.T, cache *TrackCache, id string, size int, age time.Duration) {
+	if err := cache.Store(id, strings.NewReader(strings.Repeat("x", size)), false); err != nil {
+		t.Fatal(err)
+	}
+	played := time.Now().Add(-age)
+	if err := os.Chtimes(cache.path(id), played, played); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackCacheEvict(t *testing.T) {
+	cache := newTestTrackCache(t)
+	storeTrack(t, cache, "pinned", 10, 5*time.Hour)
+	storeTrack(t, cache, "queued", 10, 4*time.Hour)
+	storeTrack(t, cache, "oldest", 10, 3*time.Hour)
+	storeTrack(t, cache, "older", 10, 2*time.Hour)
+	storeTrack(t, cache, "newest", 10, time.Hour)
+	if err := cache.Pin([]string{"pinned"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the songs played least recently go first, leaving pinned and queued
+	// songs however old they are
+	cache.MaxSize = 35
+	if err := cache.Evict([]string{"queued"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{"pinned": true, "queued": true, "oldest": false, "older": false, "newest": true} {
+		if has := cache.Has(id); has != want {
+			t.Errorf("after evicting, has %s is %v, want %v", id, has, want)
+		}
+	}
+
+	// the cache is left over MaxSize rather than removing any of them
+	cache.MaxSize = 1
+	if err := cache.Evict([]string{"queued"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{"pinned": true, "queued": true, "newest": false} {
+		if has := cache.Has(id); has != want {
+			t.Errorf("after evicting everything, has %s is %v, want %v", id, has, want)
+		}
+	}
+}
+
+func TestTrackCacheEvictWithoutLimit(t *testing.T) {
+	cache := newTestTrackCache(t)
+	storeTrack(t, cache, "song", 10, time.Hour)
+
+	if err := cache.Evict(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.Has("song") {
+		t.Error("song was evicted from a cache without a size limit")
+	}
+}
+
+func TestTrackCacheHasDownload(t *testing.T) {
+	cache := newTestTrackCache(t)
+	if cache.HasDownload("song", true) {
+		t.Error("has a song that was never downloaded")
+	}
+
+	// a prefetched, transcoded copy doesn't do for a pin of the original
+	if err := cache.Store("song", strings.NewReader("transcoded"), true); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.HasDownload("song", true) {
+		t.Error("transcoded song doesn't do for a transcoded download")
+	}
+	if cache.HasDownload("song", false) {
+		t.Error("transcoded song does for a download of the original")
+	}
+
+	// the original does for either, and is remembered as such
+	if err := cache.Store("song", strings.NewReader("original"), false); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.HasDownload("song", true) || !cache.HasDownload("song", false) {
+		t.Error("original song doesn't do for every download")
+	}
+	transcoded, err := loadIds(filepath.Join(cache.dir, transcodedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcoded) != 0 {
+		t.Errorf("songs saved as transcoded are %v, want none", transcoded)
+	}
+}
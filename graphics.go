@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// Terminal graphics protocols used to draw cover art. Terminals that support
+// none of them get the art drawn with Unicode half blocks instead.
+const (
+	GraphicsAuto   = "auto"
+	GraphicsKitty  = "kitty"
+	GraphicsITerm  = "iterm"
+	GraphicsSixel  = "sixel"
+	GraphicsBlocks = "blocks"
+	GraphicsNone   = "none"
+)
+
+// cells are about twice as tall as they are wide, and sixel images need
+// their size in pixels. Most terminal fonts are close enough to this.
+const (
+	cellPixelWidth  = 10
+	cellPixelHeight = 20
+)
+
+// detectGraphics guesses which graphics protocol the terminal supports from
+// the environment. Sixel can't be detected this way, so it has to be set in
+// the config.
+func detectGraphics() string {
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(os.Getenv("TERM"), "kitty"):
+		return GraphicsKitty
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return GraphicsITerm
+	}
+	return GraphicsBlocks
+}
+
+// fitCells returns the largest size in cells that img fits in, inside a
+// width by height area, keeping its aspect ratio
+func fitCells(img image.Image, width int, height int) (int, int) {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 || width <= 0 || height <= 0 {
+		return 0, 0
+	}
+
+	// one cell is two "pixels" high
+	cols := width
+	rows := cols * bounds.Dy() / bounds.Dx() / 2
+	if rows > height {
+		rows = height
+		cols = rows * 2 * bounds.Dx() / bounds.Dy()
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return cols, rows
+}
+
+// resizeImage scales img to width by height pixels, averaging the pixels that
+// make up each new one
+func resizeImage(img image.Image, width int, height int) *image.RGBA {
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := img.Bounds()
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, _ := img.At(sx, sy).RGBA()
+					r, g, b, n = r+pr, g+pg, b+pb, n+1
+				}
+			}
+			resized.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), 0xff})
+		}
+	}
+	return resized
+}
+
+// kittyImage returns the escape sequence that draws img over cols by rows
+// cells with the kitty graphics protocol. The image is sent as a PNG, in
+// chunks of at most 4096 bytes.
+func kittyImage(img image.Image, cols int, rows int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var out strings.Builder
+	for first := true; len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		data = data[len(chunk):]
+
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&out, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return out.String(), nil
+}
+
+// kittyDelete removes every image drawn with kittyImage
+const kittyDelete = "\x1b_Ga=d,q=2\x1b\\"
+
+// itermImage returns the escape sequence that draws img over cols by rows
+// cells with the iTerm2 inline image protocol
+func itermImage(img image.Image, cols int, rows int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+		buf.Len(), cols, rows, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// sixelImage returns the sixel escape sequence that draws img over cols by
+// rows cells. Colours are reduced to a 6x6x6 colour cube.
+func sixelImage(img image.Image, cols int, rows int) string {
+	width, height := cols*cellPixelWidth, rows*cellPixelHeight
+	resized := resizeImage(img, width, height)
+
+	// index into the colour cube of every pixel
+	palette := make([]int, width*height)
+	used := make(map[int]bool)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := resized.RGBAAt(x, y)
+			index := int(c.R)*6/256*36 + int(c.G)*6/256*6 + int(c.B)*6/256
+			palette[y*width+x] = index
+			used[index] = true
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "\x1bP0;1;0q\"1;1;%d;%d", width, height)
+	for index := range used {
+		// sixel colours are percentages
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", index, index/36*20, index/6%6*20, index%6*20)
+	}
+
+	// the image is drawn in bands six pixels high, one colour at a time
+	for band := 0; band < height; band += 6 {
+		bandColours := make(map[int]bool)
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				bandColours[palette[y*width+x]] = true
+			}
+		}
+
+		for index := range bandColours {
+			fmt.Fprintf(&out, "#%d", index)
+
+			var previous byte
+			run := 0
+			for x := 0; x < width; x++ {
+				var bits byte
+				for bit := 0; bit < 6 && band+bit < height; bit++ {
+					if palette[(band+bit)*width+x] == index {
+						bits |= 1 << uint(bit)
+					}
+				}
+
+				char := 63 + bits
+				if run > 0 && char != previous {
+					writeSixelRun(&out, previous, run)
+					run = 0
+				}
+				previous = char
+				run++
+			}
+			writeSixelRun(&out, previous, run)
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+
+	out.WriteString("\x1b\\")
+	return out.String()
+}
+
+func writeSixelRun(out *strings.Builder, char byte, run int) {
+	if run > 3 {
+		fmt.Fprintf(out, "!%d%c", run, char)
+		return
+	}
+	for i := 0; i < run; i++ {
+		out.WriteByte(char)
+	}
+}
+
+// cursorTo moves the cursor to a cell, counting from 0
+func cursorTo(x int, y int) string {
+	return fmt.Sprintf("\x1b[%d;%dH", y+1, x+1)
+}
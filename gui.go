@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,58 +16,164 @@ import (
 
 // struct contains all the updatable elements of the Ui
 type Ui struct {
-	app               *tview.Application
-	pages             *tview.Pages
-	entityList        *tview.List
-	queueList         *tview.List
-	playlistList      *tview.List
-	addToPlaylistList *tview.List
-	selectedPlaylist  *tview.List
-	newPlaylistInput  *tview.InputField
-	startStopStatus   *tview.TextView
-	currentPage       *tview.TextView
-	playerStatus      *tview.TextView
-	logList           *tview.List
-	searchField       *tview.InputField
-	currentDirectory  *SubsonicDirectory
-	artistList        *tview.List
-	artistIdList      []string
-	starIdList        map[string]struct{}
-	playlists         []SubsonicPlaylist
-	connection        *SubsonicConnection
-	player            *Player
-	scrobbleTimer     *time.Timer
+	app                 *tview.Application
+	pages               *tview.Pages
+	entityList          *tview.List
+	queueList           *tview.List
+	playlistList        *tview.List
+	addToPlaylistList   *tview.List
+	addToPlaylistSong   *SubsonicEntity
+	addToPlaylistFrom   *tview.List
+	selectedPlaylist    *tview.List
+	newPlaylistInput    *tview.InputField
+	startStopStatus     *tview.TextView
+	currentPage         *tview.TextView
+	playerStatus        *tview.TextView
+	logList             *tview.List
+	searchField         *tview.InputField
+	currentDirectory    *SubsonicDirectory
+	tagBrowsing         bool
+	artistList          *tview.List
+	artistIdList        []string
+	starIdList          map[string]struct{}
+	playlists           []SubsonicPlaylist
+	albumList           *tview.List
+	albums              []SubsonicAlbum
+	albumsLoaded        bool
+	albumListType       int
+	albumListOffset     int
+	genreList           *tview.List
+	genreSongList       *tview.List
+	genres              []SubsonicGenre
+	genresLoaded        bool
+	selectedGenre       string
+	genreSongs          SubsonicEntities
+	genreSongOffset     int
+	randomSongsForm     *tview.Form
+	randomSongsReturn   tview.Primitive
+	radioList           *tview.List
+	radioStations       []SubsonicRadioStation
+	radioStationsLoaded bool
+	radioStationForm    *tview.Form
+	editedRadioStation  *SubsonicRadioStation
+	user                *SubsonicUser
+	podcastList         *tview.List
+	episodeList         *tview.List
+	podcastChannels     []SubsonicPodcastChannel
+	podcastsLoaded      bool
+	episodes            []SubsonicPodcastEpisode
+	podcastPositions    map[string]float64
+	newPodcastInput     *tview.InputField
+	bookmarkList        *tview.List
+	bookmarkRows        []bookmarkRow
+	bookmarksLoaded     bool
+	pendingRating       bool
+	lyricsView          *tview.TextView
+	lyrics              *SubsonicStructuredLyrics
+	lyricsSongId        string
+	lyricsLine          int
+	coverArt            *CoverArtView
+	nowPlayingText      *tview.TextView
+	nowPlayingId        string
+	shareList           *tview.List
+	shares              []SubsonicShare
+	sharesLoaded        bool
+	shareForm           *tview.Form
+	shareId             string
+	shareReturn         tview.Primitive
+	clipboardText       string
+	artistRadioSeed     *artistRadioSeed
+	artistRadioSongs    map[string]struct{}
+	artistRadioFetching bool
+	musicFolderList     *tview.List
+	musicFolderReturn   tview.Primitive
+	musicFolderName     string
+	serverList          *tview.List
+	serverReturn        tview.Primitive
+	streamingProfiles   []StreamingProfile
+	streamingProfile    int
+	downloadQueue       chan downloadRequest
+	prefetchQueue       chan downloadRequest
+	downloadsStop       chan struct{}
+	switchingServer     bool
+	downloadsPending    map[string]bool
+	searchInput         *tview.InputField
+	searchArtistList    *tview.List
+	searchAlbumList     *tview.List
+	searchSongList      *tview.List
+	searchQuery         string
+	searchResult        SubsonicSearchResult
+	searchArtistOffset  int
+	searchAlbumOffset   int
+	searchSongOffset    int
+	connection          *SubsonicConnection
+	player              *Player
+	scrobbleTimer       *time.Timer
+	streamTitle         string
+	savedPlayQueue      string
+	restoredSongId      string
+	restoredPosition    float64
 }
 
 func (ui *Ui) handleEntitySelected(directoryId string) {
-	response, err := ui.connection.GetMusicDirectory(directoryId)
-	sort.Sort(response.Directory.Entities)
+	directory, err := fetchDirectory(ui.connection, directoryId, false)
 	if err != nil {
 		ui.connection.Logger.Printf("handleEntitySelected: GetMusicDirectory %s -- %s", directoryId, err.Error())
+		return
 	}
 
-	ui.currentDirectory = &response.Directory
+	ui.showDirectory(directory, ui.makeEntityHandler(directory.Parent), ui.makeEntityHandler)
+}
+
+// handleArtistSelected shows the albums of an artist when browsing by tags
+func (ui *Ui) handleArtistSelected(artistId string) {
+	directory, err := fetchDirectory(ui.connection, artistId, true)
+	if err != nil {
+		ui.connection.Logger.Printf("handleArtistSelected: GetArtist %s -- %s", artistId, err.Error())
+		return
+	}
+
+	ui.showDirectory(directory, nil, ui.makeAlbumHandler)
+}
+
+// handleAlbumSelected shows the songs of an album when browsing by tags
+func (ui *Ui) handleAlbumSelected(albumId string) {
+	response, err := ui.connection.GetAlbum(albumId)
+	if err != nil {
+		ui.connection.Logger.Printf("handleAlbumSelected: GetAlbum %s -- %s", albumId, err.Error())
+		return
+	}
+	sort.Sort(response.Album.Songs)
+
+	directory := SubsonicDirectory{
+		Id:       response.Album.Id,
+		Parent:   response.Album.ArtistId,
+		Name:     response.Album.Artist,
+		Entities: response.Album.Songs,
+	}
+
+	ui.showDirectory(&directory, ui.makeArtistHandler(directory.Parent), ui.makeAlbumHandler)
+}
+
+// showDirectory fills the entity list with the contents of a directory.
+// parentHandler is bound to the [..] entry, and makeHandler creates the
+// handlers for any subdirectories.
+func (ui *Ui) showDirectory(directory *SubsonicDirectory, parentHandler func(), makeHandler func(string) func()) {
+	ui.currentDirectory = directory
 	ui.entityList.Clear()
-	if response.Directory.Parent != "" {
-		ui.entityList.AddItem(tview.Escape("[..]"), "", 0,
-			ui.makeEntityHandler(response.Directory.Parent))
+	if directory.Parent != "" {
+		ui.entityList.AddItem(tview.Escape("[..]"), "", 0, parentHandler)
 	}
 
-	for _, entity := range response.Directory.Entities {
-		var title string
-		var id = entity.Id
+	for _, entity := range directory.Entities {
 		var handler func()
 		if entity.IsDirectory {
-			title = tview.Escape("[" + entity.Title + "]")
-			handler = ui.makeEntityHandler(entity.Id)
+			handler = makeHandler(entity.Id)
 		} else {
-			title = entityListTextFormat(entity, ui.starIdList )
-			handler = makeSongHandler(id, ui.connection.GetPlayUrl(&entity),
-				title, stringOr(entity.Artist, response.Directory.Name),
-				entity.Duration, ui.player, ui.queueList, ui.starIdList)
+			handler = makeSongHandler(ui.makeQueueItem(&entity), ui.player, ui.queueList, ui.starIdList)
 		}
 
-		ui.entityList.AddItem(title, "", 0, handler)
+		ui.entityList.AddItem(entityListTextFormat(entity, ui.starIdList, ui.downloadState(entity.Id)), "", 0, handler)
 	}
 }
 
@@ -77,10 +184,8 @@ func (ui *Ui) handlePlaylistSelected(playlist SubsonicPlaylist) {
 		var title string
 		var handler func()
 
-		var id = entity.Id
-
-		title = entity.getSongTitle()
-		handler = makeSongHandler(id, ui.connection.GetPlayUrl(&entity), title, entity.Artist, entity.Duration, ui.player, ui.queueList, ui.starIdList)
+		title = entity.getSongTitle() + downloadedText(ui.downloadState(entity.Id))
+		handler = makeSongHandler(ui.makeQueueItem(&entity), ui.player, ui.queueList, ui.starIdList)
 
 		ui.selectedPlaylist.AddItem(title, "", 0, handler)
 	}
@@ -88,36 +193,50 @@ func (ui *Ui) handlePlaylistSelected(playlist SubsonicPlaylist) {
 
 func (ui *Ui) handleDeleteFromQueue() {
 	currentIndex := ui.queueList.GetCurrentItem()
-	queue := ui.player.Queue
 
-	if currentIndex == -1 || len(ui.player.Queue) < currentIndex {
+	if currentIndex == -1 || len(ui.player.Queue) <= currentIndex {
 		return
 	}
 
-	// if the deleted item was the first one, and the player is loaded
-	// remove the track. Removing the track auto starts the next one
-	if currentIndex == 0 {
-		if isSongLoaded, err := ui.player.IsSongLoaded(); err != nil {
-			ui.connection.Logger.Printf("handleDeleteFromQueue: IsSongLoaded -- %s", err.Error())
-			return
-		} else if isSongLoaded {
-			ui.player.Stop()
-		}
-		return
+	// removing the current item plays the next one, if it was playing
+	if err := ui.player.Remove(currentIndex); err != nil {
+		ui.connection.Logger.Printf("handleDeleteFromQueue: Remove -- %s", err.Error())
 	}
 
-	// remove the item from the queue
-	if len(ui.player.Queue) > 1 {
-		ui.player.Queue = append(queue[:currentIndex], queue[currentIndex+1:]...)
-	} else {
-		ui.player.Queue = make([]QueueItem, 0)
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateNowPlaying()
+}
+
+// handleNextTrack skips to the next item of the queue
+func (ui *Ui) handleNextTrack() {
+	if err := ui.player.Next(); err != nil {
+		ui.connection.Logger.Printf("handleNextTrack: Next -- %s", err.Error())
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateNowPlaying()
+}
+
+// handlePreviousTrack goes back to the previous item of the queue, or starts
+// the current one over
+func (ui *Ui) handlePreviousTrack() {
+	if err := ui.player.Previous(); err != nil {
+		ui.connection.Logger.Printf("handlePreviousTrack: Previous -- %s", err.Error())
 	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateNowPlaying()
+}
 
+// handlePlayQueueItem plays the selected item of the queue, keeping the items
+// before and after it
+func (ui *Ui) handlePlayQueueItem(index int) {
+	if err := ui.player.Play(index); err != nil {
+		ui.connection.Logger.Printf("handlePlayQueueItem: Play -- %s", err.Error())
+	}
 	updateQueueList(ui.player, ui.queueList, ui.starIdList)
 }
 
 func (ui *Ui) handleAddRandomSongs() {
-	ui.addRandomSongsToQueue()
+	ui.addRandomSongsToQueue(randomSongFilterFromConfig())
 	updateQueueList(ui.player, ui.queueList, ui.starIdList)
 }
 
@@ -125,31 +244,17 @@ func (ui *Ui) handleToggleStar() {
 	currentIndex := ui.queueList.GetCurrentItem()
 	queue := ui.player.Queue
 
-	if currentIndex == -1 || len(ui.player.Queue) < currentIndex {
+	if currentIndex < 0 || currentIndex >= len(queue) {
 		return
 	}
 
 	var entity = queue[currentIndex]
 
-	// If the song is already in the star list, remove it
-	_, remove := ui.starIdList[entity.Id]
-
-	// resp, _ := ui.connection.ToggleStar(entity.Id, remove)
-	ui.connection.ToggleStar(entity.Id, ui.starIdList)
+	ui.toggleStarred(entity.Id, ui.connection.ToggleStar)
 
-	if (remove) {
-		delete(ui.starIdList, entity.Id)
-	} else {
-		ui.starIdList[entity.Id] = struct{}{}
-	}
-
-	var text = queueListTextFormat(ui.player.Queue[currentIndex], ui.starIdList )
-	updateQueueListItem(ui.queueList, currentIndex, text)
+	updateQueueListItem(ui.player, ui.queueList, currentIndex, ui.starIdList)
 	// Update the entity list to reflect any changes
-	ui.connection.Logger.Printf("entity test", ui.currentDirectory)
-	if (ui.currentDirectory != nil) {
-		ui.handleEntitySelected(ui.currentDirectory.Id) 
-	}
+	ui.updateEntityListStars()
 }
 
 func (ui *Ui) handleAddEntityToQueue() {
@@ -181,32 +286,55 @@ func (ui *Ui) handleAddEntityToQueue() {
 
 func (ui *Ui) handleToggleEntityStar() {
 	currentIndex := ui.entityList.GetCurrentItem()
+	entityIndex := currentIndex
 
-	var entity = ui.currentDirectory.Entities[currentIndex-1]
+	// account for the [..] entry, if there is one
+	if ui.currentDirectory.Parent != "" {
+		entityIndex--
+	}
 
-	// If the song is already in the star list, remove it
-	_, remove := ui.starIdList[entity.Id]
+	if entityIndex < 0 || len(ui.currentDirectory.Entities) <= entityIndex {
+		return
+	}
 
-	ui.connection.ToggleStar(entity.Id, ui.starIdList)
+	var entity = ui.currentDirectory.Entities[entityIndex]
 
-	if (remove) {
-		delete(ui.starIdList, entity.Id)
-	} else {
-		ui.starIdList[entity.Id] = struct{}{}
-	}
+	ui.toggleStarred(entity.Id, ui.connection.ToggleStar)
 
-	var text = entityListTextFormat(entity, ui.starIdList )
+	var text = entityListTextFormat(entity, ui.starIdList, ui.downloadState(entity.Id))
 	updateEntityListItem(ui.entityList, currentIndex, text)
 	updateQueueList(ui.player, ui.queueList, ui.starIdList)
 }
 
-func entityListTextFormat(queueItem SubsonicEntity, starredItems map[string]struct{} ) string {
+func entityListTextFormat(queueItem SubsonicEntity, starredItems map[string]struct{}, downloaded downloadState) string {
+	rating := ratingText(queueItem.UserRating, queueItem.AverageRating)
+	if queueItem.IsDirectory {
+		return tview.Escape("["+queueItem.Title+"]") + rating
+	}
+
 	var star = ""
 	_, hasStar := starredItems[queueItem.Id]
 	if hasStar {
 		star = " [red]♥"
 	}
-	return queueItem.Title + star
+	return queueItem.Title + rating + downloadedText(downloaded) + star
+}
+
+// updateEntityListStars refreshes the rows of the entity list, so they
+// reflect the current stars and ratings
+func (ui *Ui) updateEntityListStars() {
+	if ui.currentDirectory == nil {
+		return
+	}
+
+	offset := 0
+	if ui.currentDirectory.Parent != "" {
+		offset = 1
+	}
+
+	for i, entity := range ui.currentDirectory.Entities {
+		updateEntityListItem(ui.entityList, i+offset, entityListTextFormat(entity, ui.starIdList, ui.downloadState(entity.Id)))
+	}
 }
 
 // Just update the text of a specific row
@@ -247,28 +375,57 @@ func (ui *Ui) handleAddPlaylistToQueue() {
 	updateQueueList(ui.player, ui.queueList, ui.starIdList)
 }
 
-func (ui *Ui) handleAddSongToPlaylist(playlist *SubsonicPlaylist) {
-	currentIndex := ui.entityList.GetCurrentItem()
+// showAddToPlaylist opens the playlist picker for a song. Once it is closed,
+// focus goes back to the list the song was picked from.
+func (ui *Ui) showAddToPlaylist(entity *SubsonicEntity, from *tview.List) {
+	// only makes sense to add to a playlist if there are playlists
+	if entity == nil || entity.IsDirectory || ui.playlistList.GetItemCount() == 0 {
+		return
+	}
 
-	// if we have a parent directory subtract 1 to account for the [..]
-	// which would be index 0 in that case with index 1 being the first entity
-	if ui.currentDirectory.Parent != "" {
-		currentIndex--
+	ui.addToPlaylistSong = entity
+	ui.addToPlaylistFrom = from
+	ui.pages.ShowPage("addToPlaylist")
+	ui.app.SetFocus(ui.addToPlaylistList)
+}
+
+func (ui *Ui) hideAddToPlaylist() {
+	ui.pages.HidePage("addToPlaylist")
+	if ui.addToPlaylistFrom != nil {
+		ui.app.SetFocus(ui.addToPlaylistFrom)
 	}
+}
 
-	if currentIndex == -1 || len(ui.currentDirectory.Entities) < currentIndex {
+func (ui *Ui) handleAddSongToPlaylist(playlist *SubsonicPlaylist) {
+	entity := ui.addToPlaylistSong
+	if entity == nil {
 		return
 	}
 
-	entity := ui.currentDirectory.Entities[currentIndex]
-
-	if !entity.IsDirectory {
-		ui.connection.AddSongToPlaylist(string(playlist.Id), entity.Id)
+	if err := ui.connection.AddSongToPlaylist(string(playlist.Id), entity.Id); err != nil {
+		ui.connection.Logger.Printf("handleAddSongToPlaylist: AddSongToPlaylist %s -- %s", playlist.Name, err.Error())
+		return
 	}
+
 	// update the playlists
+	if err := ui.refreshPlaylists(); err != nil {
+		ui.connection.Logger.Printf("handleAddSongToPlaylist: GetPlaylists -- %s", err.Error())
+		return
+	}
+
+	if from := ui.addToPlaylistFrom; from != nil {
+		currentIndex := from.GetCurrentItem()
+		if currentIndex+1 < from.GetItemCount() {
+			from.SetCurrentItem(currentIndex + 1)
+		}
+	}
+}
+
+// refreshPlaylists reloads the playlists into the playlist lists
+func (ui *Ui) refreshPlaylists() error {
 	response, err := ui.connection.GetPlaylists()
 	if err != nil {
-		ui.connection.Logger.Printf("handleAddSongToPlaylist: GetPlaylists -- %s", err.Error())
+		return err
 	}
 	ui.playlists = response.Playlists.Playlists
 
@@ -279,16 +436,36 @@ func (ui *Ui) handleAddSongToPlaylist(playlist *SubsonicPlaylist) {
 		ui.playlistList.AddItem(playlist.Name, "", 0, nil)
 		ui.addToPlaylistList.AddItem(playlist.Name, "", 0, nil)
 	}
+	return nil
+}
 
-	if currentIndex+1 < ui.entityList.GetItemCount() {
-		ui.entityList.SetCurrentItem(currentIndex + 1)
+// selectedEntity returns the entity under the cursor in the entity list, or
+// nil if the cursor is on the [..] entry
+func (ui *Ui) selectedEntity() *SubsonicEntity {
+	if ui.currentDirectory == nil {
+		return nil
+	}
+
+	currentIndex := ui.entityList.GetCurrentItem()
+
+	// if we have a parent directory subtract 1 to account for the [..]
+	// which would be index 0 in that case with index 1 being the first entity
+	if ui.currentDirectory.Parent != "" {
+		currentIndex--
+	}
+
+	if currentIndex < 0 || len(ui.currentDirectory.Entities) <= currentIndex {
+		return nil
 	}
+
+	return &ui.currentDirectory.Entities[currentIndex]
 }
 
-func (ui *Ui) addRandomSongsToQueue() {
-	response, err := ui.connection.GetRandomSongs()
-	if (err != nil) {
-		ui.connection.Logger.Printf("addRandomSongsToQueue", err.Error())
+func (ui *Ui) addRandomSongsToQueue(filter RandomSongFilter) {
+	response, err := ui.connection.GetRandomSongs(filter)
+	if err != nil {
+		ui.connection.Logger.Printf("addRandomSongsToQueue: GetRandomSongs -- %s", err.Error())
+		return
 	}
 	for _, e := range response.RandomSongs.Song {
 		ui.addSongToQueue(&e)
@@ -296,32 +473,101 @@ func (ui *Ui) addRandomSongsToQueue() {
 }
 
 func (ui *Ui) addStarredToList() {
-	response, err := ui.connection.GetStarred()
-	if (err != nil) {
-		ui.connection.Logger.Printf("addStarredToList", err.Error())
+	starred, err := fetchStarred(ui.connection)
+	if err != nil {
+		ui.connection.Logger.Printf("addStarredToList: GetStarred -- %s", err.Error())
+		return
+	}
+	for id := range starred {
+		ui.starIdList[id] = struct{}{}
+	}
+}
+
+// fetchStarred returns the ids of the starred songs, albums and artists. It
+// doesn't touch the UI, so it can be called from any goroutine.
+func fetchStarred(connection *SubsonicConnection) (map[string]struct{}, error) {
+	response, err := connection.GetStarred()
+	if err != nil {
+		return nil, err
+	}
+
+	// We're storing empty struct as values as we only want the indexes
+	// It's faster having direct index access instead of looping through array values
+	starred := make(map[string]struct{})
+	for _, e := range response.Starred.Songs {
+		starred[e.Id] = struct{}{}
 	}
-	for _, e := range response.Starred.Song {
-		// We're storing empty struct as values as we only want the indexes
-		// It's faster having direct index access instead of looping through array values
-		ui.starIdList[e.Id] = struct{}{}
+	for _, album := range response.Starred.Albums {
+		starred[album.Id] = struct{}{}
 	}
+	for _, artist := range response.Starred.Artists {
+		starred[artist.Id] = struct{}{}
+	}
+	return starred, nil
 }
 
 func (ui *Ui) addDirectoryToQueue(entity *SubsonicEntity) {
+	songs := ui.directorySongs(entity)
+	for i := range songs {
+		ui.addSongToQueue(&songs[i])
+	}
+}
+
+func (ui *Ui) addAlbumToQueue(albumId string) {
+	songs := ui.albumSongs(albumId)
+	for i := range songs {
+		ui.addSongToQueue(&songs[i])
+	}
+}
+
+// directorySongs returns the songs in a directory and all of its
+// subdirectories, in order
+func (ui *Ui) directorySongs(entity *SubsonicEntity) []SubsonicEntity {
+	if entity.isAlbum {
+		return ui.albumSongs(entity.Id)
+	}
+
 	response, err := ui.connection.GetMusicDirectory(entity.Id)
 	if err != nil {
-		ui.connection.Logger.Printf("addDirectoryToQueue: GetMusicDirectory %s -- %s", entity.Id, err.Error())
-		return
+		ui.connection.Logger.Printf("directorySongs: GetMusicDirectory %s -- %s", entity.Id, err.Error())
+		return nil
 	}
 
+	var songs []SubsonicEntity
 	sort.Sort(response.Directory.Entities)
 	for _, e := range response.Directory.Entities {
 		if e.IsDirectory {
-			ui.addDirectoryToQueue(&e)
+			songs = append(songs, ui.directorySongs(&e)...)
 		} else {
-			ui.addSongToQueue(&e)
+			songs = append(songs, e)
+		}
+	}
+	return songs
+}
+
+func (ui *Ui) albumSongs(albumId string) []SubsonicEntity {
+	response, err := ui.connection.GetAlbum(albumId)
+	if err != nil {
+		ui.connection.Logger.Printf("albumSongs: GetAlbum %s -- %s", albumId, err.Error())
+		return nil
+	}
+
+	sort.Sort(response.Album.Songs)
+	return response.Album.Songs
+}
+
+// currentUser returns the logged in user and their roles, which are fetched
+// the first time they're needed
+func (ui *Ui) currentUser() *SubsonicUser {
+	if ui.user == nil {
+		response, err := ui.connection.GetUser(ui.connection.Username)
+		if err != nil {
+			ui.connection.Logger.Printf("currentUser: GetUser -- %s", err.Error())
+			return &SubsonicUser{}
 		}
+		ui.user = &response.User
 	}
+	return ui.user
 }
 
 func (ui *Ui) search() {
@@ -366,26 +612,37 @@ func (ui *Ui) searchPrev() {
 }
 
 func (ui *Ui) addSongToQueue(entity *SubsonicEntity) {
+	ui.player.Queue = append(ui.player.Queue, ui.makeQueueItem(entity))
+	// the song may be next up
+	if ui.player.Remaining() <= viper.GetInt("prefetch.tracks")+1 {
+		ui.prefetchNext()
+	}
+}
+
+func (ui *Ui) makeQueueItem(entity *SubsonicEntity) QueueItem {
 	uri := ui.connection.GetPlayUrl(entity)
 
 	var artist string
 	if ui.currentDirectory == nil {
 		artist = entity.Artist
 	} else {
-		stringOr(entity.Artist, ui.currentDirectory.Name)
+		artist = stringOr(entity.Artist, ui.currentDirectory.Name)
 	}
 
 	var id = entity.Id
 
-
-	queueItem := QueueItem{
-		id,
-		uri,
-		entity.getSongTitle(),
-		artist,
-		entity.Duration,
+	return QueueItem{
+		Id:            id,
+		Uri:           uri,
+		Title:         entity.getSongTitle(),
+		Artist:        artist,
+		Album:         entity.Album,
+		Duration:      entity.Duration,
+		CoverArt:      entity.CoverArt,
+		UserRating:    entity.UserRating,
+		AverageRating: entity.AverageRating,
+		Pinned:        ui.isPinned(id),
 	}
-	ui.player.Queue = append(ui.player.Queue, queueItem)
 }
 
 func (ui *Ui) newPlaylist(name string) {
@@ -408,6 +665,11 @@ func (ui *Ui) deletePlaylist(index int) {
 
 	playlist := ui.playlists[index]
 
+	if err := ui.connection.DeletePlaylist(string(playlist.Id)); err != nil {
+		ui.connection.Logger.Printf("deletePlaylist: DeletePlaylist %s -- %s", playlist.Name, err.Error())
+		return
+	}
+
 	if index == 0 {
 		ui.playlistList.SetCurrentItem(1)
 	}
@@ -417,12 +679,11 @@ func (ui *Ui) deletePlaylist(index int) {
 
 	ui.playlistList.RemoveItem(index)
 	ui.addToPlaylistList.RemoveItem(index)
-	ui.connection.DeletePlaylist(string(playlist.Id))
 }
 
-func makeSongHandler(id string, uri string, title string, artist string, duration int, player *Player, queueList *tview.List, starIdList map[string]struct{}) func() {
+func makeSongHandler(item QueueItem, player *Player, queueList *tview.List, starIdList map[string]struct{}) func() {
 	return func() {
-		player.Play(id, uri, title, artist, duration)
+		player.Replace([]QueueItem{item})
 		updateQueueList(player, queueList, starIdList)
 	}
 }
@@ -433,6 +694,110 @@ func (ui *Ui) makeEntityHandler(directoryId string) func() {
 	}
 }
 
+func (ui *Ui) makeArtistHandler(artistId string) func() {
+	return func() {
+		ui.handleArtistSelected(artistId)
+	}
+}
+
+func (ui *Ui) makeAlbumHandler(albumId string) func() {
+	return func() {
+		ui.handleAlbumSelected(albumId)
+	}
+}
+
+// handleArtistIndexSelected shows the contents of an artist from the artist
+// list, using whichever browsing mode is active
+func (ui *Ui) handleArtistIndexSelected(index int) {
+	if index < 0 || index >= len(ui.artistIdList) {
+		return
+	}
+
+	if ui.tagBrowsing {
+		ui.handleArtistSelected(ui.artistIdList[index])
+	} else {
+		ui.handleEntitySelected(ui.artistIdList[index])
+	}
+}
+
+// fetchDirectory returns the contents of a directory or, when browsing by
+// tags, the albums of an artist. It doesn't touch the UI, so it can be called
+// from any goroutine.
+func fetchDirectory(connection *SubsonicConnection, id string, tagBrowsing bool) (*SubsonicDirectory, error) {
+	if tagBrowsing {
+		response, err := connection.GetArtist(id)
+		if err != nil {
+			return nil, err
+		}
+		return &SubsonicDirectory{
+			Id:       response.Artist.Id,
+			Name:     response.Artist.Name,
+			Entities: albumsToEntities(response.Artist.Albums),
+		}, nil
+	}
+
+	response, err := connection.GetMusicDirectory(id)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(response.Directory.Entities)
+	return &response.Directory, nil
+}
+
+// showFetchedDirectory shows a directory or artist fetched with
+// fetchDirectory
+func (ui *Ui) showFetchedDirectory(directory *SubsonicDirectory) {
+	if ui.tagBrowsing {
+		ui.showDirectory(directory, nil, ui.makeAlbumHandler)
+	} else {
+		ui.showDirectory(directory, ui.makeEntityHandler(directory.Parent), ui.makeEntityHandler)
+	}
+}
+
+func (ui *Ui) browserTitle() string {
+	title := "Browser"
+	if ui.tagBrowsing {
+		title += " (tags)"
+	}
+	if ui.musicFolderName != "" {
+		title += " - " + ui.musicFolderName
+	}
+	return title
+}
+
+func (ui *Ui) setArtists(indexes []SubsonicIndex) {
+	ui.artistList.Clear()
+	ui.artistIdList = nil
+	for _, index := range indexes {
+		for _, artist := range index.Artists {
+			ui.artistList.AddItem(artist.Name, "", 0, nil)
+			ui.artistIdList = append(ui.artistIdList, artist.Id)
+		}
+	}
+}
+
+// refreshArtists refetches the artist list from the server, by folder or by
+// tags depending on the browsing mode
+func (ui *Ui) refreshArtists() error {
+	var indexes []SubsonicIndex
+	if ui.tagBrowsing {
+		response, err := ui.connection.GetArtists()
+		if err != nil {
+			return err
+		}
+		indexes = response.Artists.Index
+	} else {
+		response, err := ui.connection.GetIndexes()
+		if err != nil {
+			return err
+		}
+		indexes = response.Indexes.Index
+	}
+
+	ui.setArtists(indexes)
+	return nil
+}
+
 func createUi(_ *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection *SubsonicConnection, player *Player) *Ui {
 	app := tview.NewApplication()
 	pages := tview.NewPages()
@@ -497,45 +862,63 @@ func createUi(_ *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection *Sub
 	}
 
 	ui.addStarredToList()
+	ui.podcastPositions = ui.loadPodcastPositions()
 
+	// the log pump only ever adds to the log list, so whatever logs from the
+	// UI goroutine never waits on requests to the server
 	go func() {
-		for {
-			select {
-			case msg := <-connection.Logger.prints:
-				ui.app.QueueUpdate(func() {
-					ui.logList.AddItem(msg, "", 0, nil)
-					// Make sure the log list doesn't grow infinitely
-					for ui.logList.GetItemCount() > 200 {
-						ui.logList.RemoveItem(0)
-					}
-				})
+		for msg := range connection.Logger.prints {
+			ui.app.QueueUpdate(func() {
+				ui.logList.AddItem(msg, "", 0, nil)
+				// Make sure the log list doesn't grow infinitely
+				for ui.logList.GetItemCount() > 200 {
+					ui.logList.RemoveItem(0)
+				}
+			})
+		}
+	}()
 
-			case <-scrobbleTimer.C:
-				// scrobble submission delay elapsed
+	go func() {
+		for range scrobbleTimer.C {
+			// scrobble submission delay elapsed. The player belongs to the
+			// UI goroutine, so look at it there.
+			var currentSong *QueueItem
+			var scrobbleConnection *SubsonicConnection
+			ui.app.QueueUpdate(func() {
 				paused, err := ui.player.IsPaused()
-				connection.Logger.Printf("scrobbler event: paused %v, err %v, qlen %d", paused, err, len(ui.player.Queue))
+				ui.connection.Logger.Printf("scrobbler event: paused %v, err %v, qlen %d", paused, err, len(ui.player.Queue))
 				isPlaying := err == nil && !paused
-				if len(ui.player.Queue) > 0 && isPlaying {
-					// it's still playing, submit it
-					currentSong := ui.player.Queue[0]
-					ui.connection.ScrobbleSubmission(currentSong.Id, true)
+				if item := ui.player.CurrentItem(); item != nil && isPlaying && !item.IsRadio {
+					song := *item
+					currentSong = &song
+				}
+				scrobbleConnection = ui.connection
+			})
+			if currentSong != nil {
+				// it's still playing, submit it
+				if _, err := scrobbleConnection.ScrobbleSubmission(currentSong.Id, true); err != nil {
+					scrobbleConnection.Logger.Printf("ScrobbleSubmission %s -- %s", currentSong.Title, err.Error())
 				}
 			}
 		}
 	}()
 
+	// check for queue changes to save to the server. A tick that comes while
+	// a save is still being made is dropped, so only one is ever in flight.
+	playQueueTicker := time.NewTicker(playQueueSyncInterval)
+	go func() {
+		for range playQueueTicker.C {
+			ui.syncPlayQueue()
+		}
+	}()
+
 	return &ui
 }
 
 func (ui *Ui) createBrowserPage(titleFlex *tview.Flex, indexes *[]SubsonicIndex) (*tview.Flex, tview.Primitive) {
 	// artist list, used to map the index of
 	ui.artistList = tview.NewList().ShowSecondaryText(false)
-	for _, index := range *indexes {
-		for _, artist := range index.Artists {
-			ui.artistList.AddItem(artist.Name, "", 0, nil)
-			ui.artistIdList = append(ui.artistIdList, artist.Id)
-		}
-	}
+	ui.setArtists(*indexes)
 
 	ui.searchField = tview.NewInputField().
 		SetLabel("Search:").
@@ -573,32 +956,40 @@ func (ui *Ui) createBrowserPage(titleFlex *tview.Flex, indexes *[]SubsonicIndex)
 		case keybind("searchPrev"):
 			ui.searchPrev()
 			return nil
+		case keybind("startArtistRadio"):
+			ui.handleStartArtistRadio()
+			return nil
 		case keybind("refresh"):
 			goBackTo := ui.artistList.GetCurrentItem()
-			// REFRESH artists
-			indexResponse, err := ui.connection.GetIndexes()
-			if err != nil {
-				ui.connection.Logger.Printf("Error fetching indexes from server: %s\n", err)
+			// REFRESH artists, skipping the caches
+			ui.connection.ClearCache()
+			if err := ui.refreshArtists(); err != nil {
+				ui.connection.Logger.Printf("Error fetching artists from server: %s\n", err)
 				return event
 			}
-			ui.artistList.Clear()
-			ui.connection.directoryCache = make(map[string]SubsonicResponse)
-			for _, index := range indexResponse.Indexes.Index {
-				for _, artist := range index.Artists {
-					ui.artistList.AddItem(artist.Name, "", 0, nil)
-					ui.artistIdList = append(ui.artistIdList, artist.Id)
-				}
-			}
 			// Try to put the user to about where they were
 			if goBackTo < ui.artistList.GetItemCount() {
 				ui.artistList.SetCurrentItem(goBackTo)
 			}
+		case keybind("toggleBrowseMode"):
+			ui.tagBrowsing = !ui.tagBrowsing
+			if err := ui.refreshArtists(); err != nil {
+				ui.connection.Logger.Printf("Error fetching artists from server: %s\n", err)
+				ui.tagBrowsing = !ui.tagBrowsing
+				return nil
+			}
+			ui.currentDirectory = nil
+			ui.entityList.Clear()
+			ui.artistList.SetCurrentItem(0)
+			ui.handleArtistIndexSelected(0)
+			ui.currentPage.SetText(ui.browserTitle())
+			return nil
 		}
 		return event
 	})
 
 	ui.artistList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
-		ui.handleEntitySelected(ui.artistIdList[index])
+		ui.handleArtistIndexSelected(index)
 	})
 
 	for _, playlist := range ui.playlists {
@@ -615,16 +1006,11 @@ func (ui *Ui) createBrowserPage(titleFlex *tview.Flex, indexes *[]SubsonicIndex)
 
 	ui.addToPlaylistList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEscape {
-			ui.pages.HidePage("addToPlaylist")
-			ui.pages.SwitchToPage("browser")
-			ui.app.SetFocus(ui.entityList)
+			ui.hideAddToPlaylist()
 		} else if event.Key() == tcell.KeyEnter {
 			playlist := ui.playlists[ui.addToPlaylistList.GetCurrentItem()]
 			ui.handleAddSongToPlaylist(&playlist)
-
-			ui.pages.HidePage("addToPlaylist")
-			ui.pages.SwitchToPage("browser")
-			ui.app.SetFocus(ui.entityList)
+			ui.hideAddToPlaylist()
 		}
 		return event
 	})
@@ -642,19 +1028,28 @@ func (ui *Ui) createBrowserPage(titleFlex *tview.Flex, indexes *[]SubsonicIndex)
 			ui.handleToggleEntityStar()
 			return nil
 		}
-		// only makes sense to add to a playlist if there are playlists
-		if keyName(event) == keybind("addToPlaylist") && ui.playlistList.GetItemCount() > 0 {
-			ui.pages.ShowPage("addToPlaylist")
-			ui.app.SetFocus(ui.addToPlaylistList)
+		if keyName(event) == keybind("addToPlaylist") {
+			ui.showAddToPlaylist(ui.selectedEntity(), ui.entityList)
+			return nil
+		}
+		if keyName(event) == keybind("startArtistRadio") {
+			ui.handleStartArtistRadio()
 			return nil
 		}
 		// REFRESH only the artist
 		if keyName(event) == keybind("refresh") {
 			artistIdx := ui.artistList.GetCurrentItem()
+			if artistIdx < 0 || artistIdx >= len(ui.artistIdList) {
+				return nil
+			}
 			entity := ui.artistIdList[artistIdx]
 			//ui.logger.Printf("refreshing artist idx %d, entity %s (%s)", artistIdx, entity, ui.connection.directoryCache[entity].Directory.Name)
-			delete(ui.connection.directoryCache, entity)
-			ui.handleEntitySelected(ui.artistIdList[artistIdx])
+			if ui.tagBrowsing {
+				ui.connection.forgetCached("artist-" + entity)
+			} else {
+				ui.connection.forgetCached(entity)
+			}
+			ui.handleArtistIndexSelected(artistIdx)
 			return nil
 		}
 		return event
@@ -664,9 +1059,16 @@ func (ui *Ui) createBrowserPage(titleFlex *tview.Flex, indexes *[]SubsonicIndex)
 }
 
 func (ui *Ui) createQueuePage(titleFlex *tview.Flex) *tview.Flex {
+	queueColFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(ui.queueList, 0, 2, true).
+		AddItem(ui.createNowPlaying(), 0, 1, false)
+
 	queueFlex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(titleFlex, 1, 0, false).
-		AddItem(ui.queueList, 0, 1, true)
+		AddItem(queueColFlex, 0, 1, true)
+	ui.queueList.SetSelectedFunc(func(index int, _ string, _ string, _ rune) {
+		ui.handlePlayQueueItem(index)
+	})
 	ui.queueList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyDelete || keyName(event) == keybind("removeFromQueue") {
 			ui.handleDeleteFromQueue()
@@ -735,6 +1137,18 @@ func (ui *Ui) createPlaylistPage(titleFlex *tview.Flex) (*tview.Flex, tview.Prim
 		if keyName(event) == keybind("deletePlaylist") {
 			ui.pages.ShowPage("deletePlaylist")
 		}
+		if keyName(event) == keybind("refresh") {
+			goBackTo := ui.playlistList.GetCurrentItem()
+			ui.connection.forgetPlaylists()
+			if err := ui.refreshPlaylists(); err != nil {
+				ui.connection.Logger.Printf("Error fetching playlists from server: %s\n", err)
+				return nil
+			}
+			if goBackTo < ui.playlistList.GetItemCount() {
+				ui.playlistList.SetCurrentItem(goBackTo)
+			}
+			return nil
+		}
 		return event
 	})
 
@@ -784,6 +1198,12 @@ func (ui *Ui) createPlaylistPage(titleFlex *tview.Flex) (*tview.Flex, tview.Prim
 
 func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection *SubsonicConnection, player *Player) *Ui {
 	ui := createUi(indexes, playlists, connection, player)
+	ui.streamingProfiles = streamingProfiles()
+	ui.streamingProfile, _ = streamingProfileIndex(ui.streamingProfiles, viper.GetString("streaming.profile"))
+	if connection.MusicFolderId != "" {
+		ui.musicFolderName = ui.musicFolderNameOf(connection.MusicFolderId)
+		ui.currentPage.SetText(ui.browserTitle())
+	}
 
 	// create components shared by pages
 
@@ -796,31 +1216,70 @@ func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection
 	browserFlex, addToPlaylistModal := ui.createBrowserPage(titleFlex, indexes)
 	queueFlex := ui.createQueuePage(titleFlex)
 	playlistFlex, deletePlaylistModal := ui.createPlaylistPage(titleFlex)
+	searchFlex := ui.createSearchPage(titleFlex)
+	albumsFlex := ui.createAlbumsPage(titleFlex)
+	genresFlex := ui.createGenresPage(titleFlex)
+	randomSongsModal := ui.createRandomSongsModal()
+	radioFlex, radioStationModal, deleteRadioStationModal := ui.createRadioPage(titleFlex)
+	podcastsFlex, newPodcastModal, deletePodcastModal := ui.createPodcastsPage(titleFlex)
+	bookmarksFlex := ui.createBookmarksPage(titleFlex)
+	lyricsFlex := ui.createLyricsPage(titleFlex)
+	sharesFlex, shareModal, deleteShareModal := ui.createSharesPage(titleFlex)
+	musicFolderModal := ui.createMusicFolderModal()
+	serverModal := ui.createServerModal()
 	logListFlex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(titleFlex, 1, 0, false).
 		AddItem(ui.logList, 0, 1, true)
 
 	// handle
 	go ui.handleMpvEvents()
+	ui.startDownloads()
 
 	ui.pages.AddPage("browser", browserFlex, true, true).
 		AddPage("queue", queueFlex, true, false).
 		AddPage("playlists", playlistFlex, true, false).
 		AddPage("addToPlaylist", addToPlaylistModal, true, false).
 		AddPage("deletePlaylist", deletePlaylistModal, true, false).
-		AddPage("log", logListFlex, true, false)
+		AddPage("log", logListFlex, true, false).
+		AddPage("search", searchFlex, true, false).
+		AddPage("albums", albumsFlex, true, false).
+		AddPage("genres", genresFlex, true, false).
+		AddPage("randomSongs", randomSongsModal, true, false).
+		AddPage("radio", radioFlex, true, false).
+		AddPage("radioStation", radioStationModal, true, false).
+		AddPage("deleteRadioStation", deleteRadioStationModal, true, false).
+		AddPage("podcasts", podcastsFlex, true, false).
+		AddPage("newPodcast", newPodcastModal, true, false).
+		AddPage("deletePodcast", deletePodcastModal, true, false).
+		AddPage("bookmarks", bookmarksFlex, true, false).
+		AddPage("lyrics", lyricsFlex, true, false).
+		AddPage("shares", sharesFlex, true, false).
+		AddPage("share", shareModal, true, false).
+		AddPage("deleteShare", deleteShareModal, true, false).
+		AddPage("musicFolder", musicFolderModal, true, false).
+		AddPage("server", serverModal, true, false)
 
 	ui.pages.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// we don't want any of these firing if we're trying to add a new playlist
-		focused := ui.app.GetFocus()
-		if focused == ui.newPlaylistInput || focused == ui.searchField {
+		// we don't want any of these firing if we're typing into a field, such
+		// as the new playlist name
+		switch ui.app.GetFocus().(type) {
+		case *tview.InputField, *tview.Button:
 			return event
 		}
 
+		// the key after the rate key is the rating
+		if ui.pendingRating {
+			ui.pendingRating = false
+			if rating, err := strconv.Atoi(keyName(event)); err == nil && rating >= 0 && rating <= 5 {
+				ui.handleRate(rating)
+			}
+			return nil
+		}
+
 		switch keyName(event) {
 		case keybind("pageBrowser"):
 			ui.pages.SwitchToPage("browser")
-			ui.currentPage.SetText("Browser")
+			ui.currentPage.SetText(ui.browserTitle())
 		case keybind("pageQueue"):
 			ui.pages.SwitchToPage("queue")
 			ui.currentPage.SetText("Queue")
@@ -830,19 +1289,96 @@ func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection
 		case keybind("pageLog"):
 			ui.pages.SwitchToPage("log")
 			ui.currentPage.SetText("Log")
+		case keybind("pageSearch"):
+			ui.pages.SwitchToPage("search")
+			ui.currentPage.SetText("Search")
+			ui.app.SetFocus(ui.searchInput)
+			return nil
+		case keybind("pageAlbums"):
+			// the album list is only fetched once it is first needed
+			if !ui.albumsLoaded {
+				ui.refreshAlbumList()
+			}
+			ui.pages.SwitchToPage("albums")
+			ui.currentPage.SetText("Albums")
+		case keybind("pageGenres"):
+			if !ui.genresLoaded {
+				ui.refreshGenres()
+			}
+			ui.pages.SwitchToPage("genres")
+			ui.currentPage.SetText("Genres")
+		case keybind("pageRadio"):
+			if !ui.radioStationsLoaded {
+				ui.refreshRadioStations()
+			}
+			ui.pages.SwitchToPage("radio")
+			ui.currentPage.SetText("Radio")
+		case keybind("pagePodcasts"):
+			if !ui.podcastsLoaded {
+				ui.refreshPodcasts()
+			}
+			ui.pages.SwitchToPage("podcasts")
+			ui.currentPage.SetText("Podcasts")
+		case keybind("pageBookmarks"):
+			if !ui.bookmarksLoaded {
+				ui.refreshBookmarks()
+			}
+			ui.pages.SwitchToPage("bookmarks")
+			ui.currentPage.SetText("Bookmarks")
+		case keybind("pageLyrics"):
+			ui.refreshLyrics()
+			ui.pages.SwitchToPage("lyrics")
+			ui.currentPage.SetText("Lyrics")
+		case keybind("pageShares"):
+			if !ui.sharesLoaded {
+				ui.refreshShares()
+			}
+			ui.pages.SwitchToPage("shares")
+			ui.currentPage.SetText("Shares")
+		case keybind("share"):
+			ui.showShareForm()
+			return nil
+		case keybind("musicFolder"):
+			ui.showMusicFolderPicker()
+			return nil
+		case keybind("switchServer"):
+			ui.showServerPicker()
+			return nil
+		case keybind("cycleStreamingProfile"):
+			ui.cycleStreamingProfile()
+			return nil
+		case keybind("pinOffline"):
+			ui.handlePinOffline()
+			return nil
+		case keybind("bookmark"):
+			ui.handleBookmarkCurrent()
+			return nil
+		case keybind("rate"):
+			ui.pendingRating = true
+			return nil
 		case keybind("quit"):
-			ui.player.EventChannel <- nil
+			ui.savePodcastPositions()
+			ui.savePlayQueue()
+			// the event goroutine may be waiting for this one to handle an
+			// event, in which case it's left to stop along with stmp
+			select {
+			case ui.player.EventChannel <- nil:
+			default:
+			}
 			ui.player.Instance.TerminateDestroy()
 			ui.app.Stop()
 		case keybind("addRandomSongs"):
 			ui.handleAddRandomSongs()
+		case keybind("addRandomSongsFiltered"):
+			ui.showRandomSongsForm()
+			return nil
 		case keybind("clearQueue"):
-			ui.player.Queue = make([]QueueItem, 0)
-			err := ui.player.Stop()
+			err := ui.player.Clear()
 			if err != nil {
 				ui.connection.Logger.Printf("InitGui: Stop -- %s", err.Error())
 			}
 			updateQueueList(ui.player, ui.queueList, ui.starIdList)
+			ui.updateNowPlaying()
 		case keybind("playPause"):
 			status, err := ui.player.Pause()
 			if err != nil {
@@ -852,8 +1388,8 @@ func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection
 			}
 			if status == PlayerStopped {
 				ui.startStopStatus.SetText("[::b]stmp: [red]stopped")
-			} else if status == PlayerPlaying {
-				ui.startStopStatus.SetText("[::b]stmp: [green]playing " + ui.player.Queue[0].Title)
+			} else if status == PlayerPlaying && ui.player.CurrentItem() != nil {
+				ui.startStopStatus.SetText(playingStatus(*ui.player.CurrentItem(), ui.streamTitle))
 			} else if status == PlayerPaused {
 				ui.startStopStatus.SetText("[::b]stmp: [yellow]paused")
 			}
@@ -873,6 +1409,12 @@ func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection
 				ui.connection.Logger.Printf("InitGui: Seek %d -- %s", 10, err.Error())
 			}
 			return nil
+		case keybind("nextTrack"):
+			ui.handleNextTrack()
+			return nil
+		case keybind("previousTrack"):
+			ui.handlePreviousTrack()
+			return nil
 		case keybind("seekBack"):
 			if err := ui.player.Seek(-10); err != nil {
 				ui.connection.Logger.Printf("InitGui: Seek %d -- %s", -10, err.Error())
@@ -887,33 +1429,56 @@ func InitGui(indexes *[]SubsonicIndex, playlists *[]SubsonicPlaylist, connection
 		return event
 	})
 
-	if err := ui.app.SetRoot(ui.pages, true).SetFocus(ui.pages).EnableMouse(true).Run(); err != nil {
-		panic(err)
-	}
+	ui.offerPlayQueueRestore()
 
 	return ui
 }
 
+// Run shows the UI, until it's quit
+func (ui *Ui) Run() {
+	if err := ui.app.SetRoot(ui.pages, true).SetFocus(ui.pages).EnableMouse(true).Run(); err != nil {
+		panic(err)
+	}
+}
 
-func queueListTextFormat(queueItem QueueItem, starredItems map[string]struct{} ) string {
+func queueListTextFormat(queueItem QueueItem, starredItems map[string]struct{}) string {
 	min, sec := iSecondsToMinAndSec(queueItem.Duration)
 	var star = ""
 	_, hasStar := starredItems[queueItem.Id]
 	if hasStar {
 		star = " [red]♥"
 	}
-	return fmt.Sprintf("%s - %s - %02d:%02d %s", queueItem.Title, queueItem.Artist, min, sec,star)
+	rating := ratingText(queueItem.UserRating, queueItem.AverageRating)
+	downloaded := downloadedText(queueItem.downloadState())
+	return fmt.Sprintf("%s - %s - %02d:%02d%s%s %s", queueItem.Title, queueItem.Artist, min, sec, rating, downloaded, star)
+}
+
+// queueListItemText styles the text of a queue item by where it is in the
+// queue: played items are dimmed, and the current one is bold
+func queueListItemText(player *Player, index int, starredItems map[string]struct{}) string {
+	text := queueListTextFormat(player.Queue[index], starredItems)
+	if index < player.Current {
+		return "[::d]" + text
+	} else if index == player.Current {
+		return "[::b]" + text
+	}
+	return text
 }
 
 // Just update the text of a specific row
-func updateQueueListItem(queueList *tview.List, id int, text string) {
-	queueList.SetItemText(id, text, "")
+func updateQueueListItem(player *Player, queueList *tview.List, id int, starredItems map[string]struct{}) {
+	queueList.SetItemText(id, queueListItemText(player, id, starredItems), "")
 }
 
 func updateQueueList(player *Player, queueList *tview.List, starredItems map[string]struct{}) {
+	// keep the selection, which no longer moves as songs finish
+	selected := queueList.GetCurrentItem()
 	queueList.Clear()
-	for _, queueItem := range player.Queue {
-		queueList.AddItem(queueListTextFormat(queueItem, starredItems), "", 0, nil)
+	for i := range player.Queue {
+		queueList.AddItem(queueListItemText(player, i, starredItems), "", 0, nil)
+	}
+	if selected < queueList.GetItemCount() {
+		queueList.SetCurrentItem(selected)
 	}
 }
 
@@ -921,86 +1486,183 @@ func (ui *Ui) handleMpvEvents() {
 	ui.player.Instance.ObserveProperty(0, "time-pos", mpv.FORMAT_DOUBLE)
 	ui.player.Instance.ObserveProperty(0, "duration", mpv.FORMAT_DOUBLE)
 	ui.player.Instance.ObserveProperty(0, "volume", mpv.FORMAT_INT64)
+	ui.player.Instance.ObserveProperty(0, "metadata/by-key/icy-title", mpv.FORMAT_STRING)
 	for {
 		e := <-ui.player.EventChannel
 		if e == nil {
 			break
-			// we don't want to update anything if we're in the process of replacing the current track
-		} else if e.Event_Id == mpv.EVENT_END_FILE && !ui.player.ReplaceInProgress {
-			ui.startStopStatus.SetText("[::b]stmp: [red]stopped")
-			// TODO it's gross that this is here, need better event handling
-			if len(ui.player.Queue) > 0 {
-				ui.player.Queue = ui.player.Queue[1:]
+		} else if e.Event_Id == mpv.EVENT_IDLE || e.Event_Id == mpv.EVENT_NONE {
+			continue
+		}
+
+		// the queue, the podcast positions and the primitives are only ever
+		// touched on the UI goroutine
+		ui.app.QueueUpdateDraw(func() {
+			ui.handleMpvEvent(e)
+		})
+	}
+}
+
+func (ui *Ui) handleMpvEvent(e *mpv.Event) {
+	// we don't want to update anything if we're in the process of replacing the current track
+	if e.Event_Id == mpv.EVENT_END_FILE && !ui.player.ReplaceInProgress {
+		ui.startStopStatus.SetText("[::b]stmp: [red]stopped")
+		endFile, ok := e.Data.(mpv.EventEndFile)
+		// only move on once the track is over, not when it was stopped
+		over := !ok || endFile.Reason == mpv.END_FILE_REASON_EOF || endFile.Reason == mpv.END_FILE_REASON_ERROR
+		// TODO it's gross that this is here, need better event handling
+		if current := ui.player.CurrentItem(); current != nil {
+			if episodeId := current.EpisodeId; episodeId != "" {
+				// a finished episode starts over next time
+				if ok && endFile.Reason == mpv.END_FILE_REASON_EOF {
+					delete(ui.podcastPositions, episodeId)
+				}
+				ui.savePodcastPositions()
 			}
-			updateQueueList(ui.player, ui.queueList, ui.starIdList)
-			err := ui.player.PlayNextTrack()
+			if over {
+				ui.player.Current++
+			}
+		}
+		updateQueueList(ui.player, ui.queueList, ui.starIdList)
+		ui.updateNowPlaying()
+		if over {
+			err := ui.player.PlayCurrent()
 			if err != nil {
-				ui.connection.Logger.Printf("handleMoveEvents: PlayNextTrack -- %s", err.Error())
+				ui.connection.Logger.Printf("handleMoveEvents: PlayCurrent -- %s", err.Error())
+			}
+		}
+	} else if e.Event_Id == mpv.EVENT_START_FILE {
+		ui.player.ReplaceInProgress = false
+		updateQueueList(ui.player, ui.queueList, ui.starIdList)
+
+		if current := ui.player.CurrentItem(); current != nil {
+			currentSong := *current
+			ui.streamTitle = ""
+			ui.startStopStatus.SetText(playingStatus(currentSong, ""))
+			ui.updateNowPlaying()
+			ui.prefetchNext()
+			ui.topUpArtistRadio(currentSong)
+			if ui.connection.Tracks != nil && !currentSong.IsRadio {
+				ui.connection.Tracks.Touch(currentSong.Id)
 			}
-		} else if e.Event_Id == mpv.EVENT_START_FILE {
-			ui.player.ReplaceInProgress = false
-			updateQueueList(ui.player, ui.queueList, ui.starIdList)
 
-			if len(ui.player.Queue) > 0 {
-				currentSong := ui.player.Queue[0]
-				ui.startStopStatus.SetText("[::b]stmp: [green]playing " + currentSong.Title)
-
-				if ui.connection.Scrobble {
-					// scrobble "now playing" event
-					ui.connection.ScrobbleSubmission(currentSong.Id, false)
-
-					// scrobble "submission" after song has been playing a bit
-					// see: https://www.last.fm/api/scrobbling
-					// A track should only be scrobbled when the following conditions have been met:
-					// The track must be longer than 30 seconds. And the track has been played for
-					// at least half its duration, or for 4 minutes (whichever occurs earlier.)
-					if currentSong.Duration > 30 {
-						scrobbleDelay := currentSong.Duration / 2
-						if scrobbleDelay > 240 {
-							scrobbleDelay = 240
-						}
-						scrobbleDuration := time.Duration(scrobbleDelay) * time.Second
-
-						ui.scrobbleTimer.Reset(scrobbleDuration)
-						ui.connection.Logger.Printf("scrobbler: timer started, %v", scrobbleDuration)
-					} else {
-						ui.connection.Logger.Printf("scrobbler: track too short")
-					}
+			if position, ok := ui.podcastPositions[currentSong.EpisodeId]; ok && currentSong.EpisodeId != "" {
+				ui.player.ResumePosition = position
+			}
+			// a restored queue picks up where it was saved, as long as its
+			// current song is the first one played
+			if ui.restoredSongId != "" {
+				if currentSong.Id == ui.restoredSongId {
+					ui.player.ResumePosition = ui.restoredPosition
 				}
+				ui.restoredSongId = ""
 			}
-		} else if e.Event_Id == mpv.EVENT_IDLE || e.Event_Id == mpv.EVENT_NONE {
-			continue
-		}
 
-		position, err := ui.player.Instance.GetProperty("time-pos", mpv.FORMAT_DOUBLE)
-		if err != nil {
-			ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "time-pos", err.Error())
-		}
-		// TODO only update these as needed
-		duration, err := ui.player.Instance.GetProperty("duration", mpv.FORMAT_DOUBLE)
-		if err != nil {
-			ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "duration", err.Error())
+			if name, _ := ui.pages.GetFrontPage(); name == "lyrics" {
+				ui.refreshLyrics()
+			}
+
+			if ui.connection.Scrobble && !currentSong.IsRadio {
+				// scrobble "now playing" event, without holding up the UI
+				go func(connection *SubsonicConnection) {
+					if _, err := connection.ScrobbleSubmission(currentSong.Id, false); err != nil {
+						connection.Logger.Printf("ScrobbleSubmission %s -- %s", currentSong.Title, err.Error())
+					}
+				}(ui.connection)
+
+				// scrobble "submission" after song has been playing a bit
+				// see: https://www.last.fm/api/scrobbling
+				// A track should only be scrobbled when the following conditions have been met:
+				// The track must be longer than 30 seconds. And the track has been played for
+				// at least half its duration, or for 4 minutes (whichever occurs earlier.)
+				if currentSong.Duration > 30 {
+					scrobbleDelay := currentSong.Duration / 2
+					if scrobbleDelay > 240 {
+						scrobbleDelay = 240
+					}
+					scrobbleDuration := time.Duration(scrobbleDelay) * time.Second
+
+					ui.scrobbleTimer.Reset(scrobbleDuration)
+					ui.connection.Logger.Printf("scrobbler: timer started, %v", scrobbleDuration)
+				} else {
+					ui.connection.Logger.Printf("scrobbler: track too short")
+				}
+			}
 		}
-		volume, err := ui.player.Instance.GetProperty("volume", mpv.FORMAT_INT64)
-		if err != nil {
-			ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "volume", err.Error())
+	} else if e.Event_Id == mpv.EVENT_FILE_LOADED && ui.player.ResumePosition > 0 {
+		if err := ui.player.SeekTo(ui.player.ResumePosition); err != nil {
+			ui.connection.Logger.Printf("handleMpvEvents: SeekTo %f -- %s", ui.player.ResumePosition, err.Error())
 		}
+		ui.player.ResumePosition = 0
+	}
 
-		if position == nil {
-			position = 0.0
+	// radio streams announce what they're playing through ICY metadata
+	if current := ui.player.CurrentItem(); current != nil && current.IsRadio {
+		if title := ui.player.StreamTitle(); title != ui.streamTitle {
+			ui.streamTitle = title
+			ui.startStopStatus.SetText(playingStatus(*current, title))
 		}
+	}
 
-		if duration == nil {
-			duration = 0.0
-		}
+	position, err := ui.player.Instance.GetProperty("time-pos", mpv.FORMAT_DOUBLE)
+	if err != nil {
+		ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "time-pos", err.Error())
+	}
+	// TODO only update these as needed
+	duration, err := ui.player.Instance.GetProperty("duration", mpv.FORMAT_DOUBLE)
+	if err != nil {
+		ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "duration", err.Error())
+	}
+	volume, err := ui.player.Instance.GetProperty("volume", mpv.FORMAT_INT64)
+	if err != nil {
+		ui.connection.Logger.Printf("handleMoveEvents (%s): GetProperty %s -- %s", e.Event_Id.String(), "volume", err.Error())
+	}
 
-		if volume == nil {
-			volume = 0
+	if position == nil {
+		position = 0.0
+	}
+
+	// remember how far into a podcast episode we are, so it can be resumed
+	if current := ui.player.CurrentItem(); current != nil && current.EpisodeId != "" && !ui.player.ReplaceInProgress {
+		if p := position.(float64); p > 0 {
+			ui.podcastPositions[current.EpisodeId] = p
 		}
+	}
+
+	if duration == nil {
+		duration = 0.0
+	}
+
+	if volume == nil {
+		volume = 0
+	}
+
+	ui.updateLyricsPosition(position.(float64))
+	ui.playerStatus.SetText(ui.streamStatus() + formatPlayerStatus(volume.(int64), position.(float64), duration.(float64)))
+}
 
-		ui.playerStatus.SetText(formatPlayerStatus(volume.(int64), position.(float64), duration.(float64)))
-		ui.app.Draw()
+// playingStatus formats the status text for the item being played. For radio
+// streams, streamTitle is the song the station says it is playing.
+func playingStatus(item QueueItem, streamTitle string) string {
+	if streamTitle != "" {
+		return "[::b]stmp: [green]playing " + tview.Escape(item.Title+": "+streamTitle)
 	}
+	return "[::b]stmp: [green]playing " + item.Title
+}
+
+// showError tells the user something they asked for failed, until they
+// dismiss it
+func (ui *Ui) showError(text string) {
+	focused := ui.app.GetFocus()
+	modal := tview.NewModal().
+		SetText(tview.Escape(text)).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(_ int, _ string) {
+			ui.pages.RemovePage("error")
+			ui.app.SetFocus(focused)
+		})
+
+	ui.pages.AddPage("error", modal, true, true)
 }
 
 func makeModal(p tview.Primitive, width, height int) tview.Primitive {
@@ -1046,6 +1708,31 @@ func stringOr(firstChoice string, secondChoice string) string {
 	return secondChoice
 }
 
+func (album SubsonicAlbum) toEntity() SubsonicEntity {
+	return SubsonicEntity{
+		Id:            album.Id,
+		IsDirectory:   true,
+		Parent:        album.ArtistId,
+		Title:         album.Name,
+		Artist:        album.Artist,
+		Duration:      album.Duration,
+		CoverArt:      album.CoverArt,
+		UserRating:    album.UserRating,
+		AverageRating: album.AverageRating,
+		isAlbum:       true,
+	}
+}
+
+// albumsToEntities converts tag based albums into directory entities, so
+// they can be listed like folders in the browser
+func albumsToEntities(albums []SubsonicAlbum) SubsonicEntities {
+	entities := make(SubsonicEntities, 0, len(albums))
+	for _, album := range albums {
+		entities = append(entities, album.toEntity())
+	}
+	return entities
+}
+
 // Return the title if present, otherwise fallback to the file path
 func (e SubsonicEntity) getSongTitle() string {
 	if e.Title != "" {
@@ -1069,7 +1756,7 @@ func (e SubsonicEntity) getSongTitle() string {
 }
 
 func keyName(event *tcell.EventKey) string {
-	if (event.Key() == tcell.KeyRune) {
+	if event.Key() == tcell.KeyRune {
 		return string(event.Rune())
 	} else {
 		return event.Name()
@@ -1079,4 +1766,3 @@ func keyName(event *tcell.EventKey) string {
 func keybind(path string) string {
 	return viper.GetString("keys." + path)
 }
-
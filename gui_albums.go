@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// the album lists the albums page cycles through, with their titles
+var albumListTypes = []struct {
+	listType string
+	title    string
+}{
+	{AlbumListNewest, "Recently added"},
+	{AlbumListRecent, "Recently played"},
+	{AlbumListFrequent, "Most played"},
+	{AlbumListHighest, "Highest rated"},
+	{AlbumListByName, "By name"},
+	{AlbumListByArtist, "By artist"},
+	{AlbumListStarred, "Starred"},
+	{AlbumListRandom, "Random"},
+}
+
+func (ui *Ui) createAlbumsPage(titleFlex *tview.Flex) *tview.Flex {
+	ui.albumList = tview.NewList().ShowSecondaryText(false)
+	ui.albumList.SetBorder(true)
+
+	albumsFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.albumList, 0, 1, true)
+
+	ui.albumList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("cycleAlbumList"):
+			ui.albumListType = (ui.albumListType + 1) % len(albumListTypes)
+			ui.albumListOffset = 0
+			ui.refreshAlbumList()
+			return nil
+		case keybind("nextPage"):
+			// a short page means there is nothing after it
+			if len(ui.albums) < albumListPageSize {
+				return nil
+			}
+			ui.albumListOffset += albumListPageSize
+			ui.refreshAlbumList()
+			return nil
+		case keybind("prevPage"):
+			if ui.albumListOffset == 0 {
+				return nil
+			}
+			ui.albumListOffset -= albumListPageSize
+			if ui.albumListOffset < 0 {
+				ui.albumListOffset = 0
+			}
+			ui.refreshAlbumList()
+			return nil
+		case keybind("refresh"):
+			ui.refreshAlbumList()
+			return nil
+		case keybind("add"):
+			ui.handleAddAlbumToQueue()
+			return nil
+		case keybind("star"):
+			ui.handleToggleAlbumStar()
+			return nil
+		}
+		return event
+	})
+
+	return albumsFlex
+}
+
+// refreshAlbumList fetches the current page of the selected album list
+func (ui *Ui) refreshAlbumList() {
+	listType := albumListTypes[ui.albumListType]
+	response, err := ui.connection.GetAlbumList2(listType.listType, ui.albumListOffset, "", 0, 0)
+	if err != nil {
+		ui.connection.Logger.Printf("refreshAlbumList: GetAlbumList2 %s -- %s", listType.listType, err.Error())
+		return
+	}
+
+	ui.albums = response.AlbumList.Albums
+	ui.albumsLoaded = true
+
+	ui.albumList.Clear()
+	for _, album := range ui.albums {
+		ui.albumList.AddItem(ui.albumListText(album), "", 0, ui.makePlayAlbumHandler(album.Id))
+	}
+
+	title := listType.title
+	if len(ui.albums) > 0 {
+		title = fmt.Sprintf("%s %d-%d", title, ui.albumListOffset+1, ui.albumListOffset+len(ui.albums))
+	}
+	ui.albumList.SetTitle(title)
+}
+
+func (ui *Ui) albumListText(album SubsonicAlbum) string {
+	text := album.Name + " - " + album.Artist
+	if album.Year > 0 {
+		text += fmt.Sprintf(" (%d)", album.Year)
+	}
+	return tview.Escape(text) + ratingText(album.UserRating, album.AverageRating) + starText(album.Id, ui.starIdList)
+}
+
+func (ui *Ui) handleAddAlbumToQueue() {
+	currentIndex := ui.albumList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.albums) {
+		return
+	}
+	if currentIndex+1 < ui.albumList.GetItemCount() {
+		ui.albumList.SetCurrentItem(currentIndex + 1)
+	}
+
+	entity := ui.albums[currentIndex].toEntity()
+	ui.addDirectoryToQueue(&entity)
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+func (ui *Ui) handleToggleAlbumStar() {
+	currentIndex := ui.albumList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.albums) {
+		return
+	}
+
+	album := ui.albums[currentIndex]
+	ui.toggleStarred(album.Id, ui.connection.ToggleAlbumStar)
+	ui.albumList.SetItemText(currentIndex, ui.albumListText(album), "")
+}
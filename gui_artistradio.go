@@ -0,0 +1,195 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+)
+
+// Artist radio fills the queue with songs similar to an artist or a song,
+// and tops it up from the same seed as it plays, so it never runs dry.
+
+const (
+	// number of similar songs fetched at a time
+	artistRadioBatchSize = 20
+	// number of the artist's own top songs the radio starts with
+	artistRadioTopSongCount = 5
+	// the queue is topped up once it gets this short
+	artistRadioTopUpThreshold = 3
+)
+
+// artistRadioSeed is what an artist radio plays songs similar to
+type artistRadioSeed struct {
+	// song, album or artist folder id, for getSimilarSongs
+	id string
+	// artist id from the ID3 tags, for getSimilarSongs2
+	artistId string
+	// artist name, for getTopSongs
+	artist string
+	name   string
+}
+
+// selectedArtistRadioSeed returns a seed for the artist, album or song
+// selected in the browser, or nil if nothing is selected
+func (ui *Ui) selectedArtistRadioSeed() *artistRadioSeed {
+	switch ui.app.GetFocus() {
+	case ui.artistList:
+		index := ui.artistList.GetCurrentItem()
+		if index < 0 || index >= len(ui.artistIdList) {
+			return nil
+		}
+		name, _ := ui.artistList.GetItemText(index)
+		seed := &artistRadioSeed{artist: name, name: name}
+		if ui.tagBrowsing {
+			seed.artistId = ui.artistIdList[index]
+		} else {
+			seed.id = ui.artistIdList[index]
+		}
+		return seed
+	case ui.entityList:
+		entity := ui.selectedEntity()
+		if entity == nil {
+			return nil
+		}
+		if entity.isAlbum {
+			return &artistRadioSeed{artistId: entity.Parent, artist: entity.Artist, name: entity.Title}
+		}
+		return &artistRadioSeed{id: entity.Id, artist: entity.Artist, name: entity.getSongTitle()}
+	}
+	return nil
+}
+
+// handleStartArtistRadio replaces the queue with a radio seeded from the
+// selection
+func (ui *Ui) handleStartArtistRadio() {
+	seed := ui.selectedArtistRadioSeed()
+	if seed == nil {
+		return
+	}
+
+	ui.artistRadioSeed = seed
+	ui.artistRadioSongs = make(map[string]struct{})
+	ui.artistRadioFetching = false
+
+	var songs SubsonicEntities
+	if seed.artist != "" {
+		response, err := ui.connection.GetTopSongs(seed.artist, artistRadioTopSongCount)
+		if err != nil {
+			ui.connection.Logger.Printf("handleStartArtistRadio: GetTopSongs %s -- %s", seed.artist, err.Error())
+		} else {
+			songs = ui.newArtistRadioSongs(response.TopSongs.Song)
+		}
+	}
+	similar, err := fetchSimilarSongs(ui.connection, seed)
+	if err != nil {
+		ui.connection.Logger.Printf("handleStartArtistRadio: %s -- %s", seed.name, err.Error())
+	} else {
+		songs = append(songs, ui.newArtistRadioSongs(similar)...)
+	}
+
+	if len(songs) == 0 {
+		ui.connection.Logger.Printf("No similar songs found for %s", seed.name)
+		ui.artistRadioSeed = nil
+		return
+	}
+
+	items := make([]QueueItem, 0, len(songs))
+	for i := range songs {
+		items = append(items, ui.makeQueueItem(&songs[i]))
+	}
+	if err := ui.player.Replace(items); err != nil {
+		ui.connection.Logger.Printf("handleStartArtistRadio: Replace -- %s", err.Error())
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.connection.Logger.Printf("Started radio from %s", seed.name)
+}
+
+// fetchSimilarSongs returns a batch of songs similar to a radio's seed. It
+// doesn't touch the UI, so it can be called from any goroutine.
+func fetchSimilarSongs(connection *SubsonicConnection, seed *artistRadioSeed) (SubsonicEntities, error) {
+	if seed.artistId != "" {
+		response, err := connection.GetSimilarSongs2(seed.artistId, artistRadioBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		return response.SimilarSongs2.Song, nil
+	}
+
+	response, err := connection.GetSimilarSongs(seed.id, artistRadioBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	return response.SimilarSongs.Song, nil
+}
+
+// newArtistRadioSongs returns the songs the radio hasn't played yet, and
+// marks them as played
+func (ui *Ui) newArtistRadioSongs(songs SubsonicEntities) SubsonicEntities {
+	var unplayed SubsonicEntities
+	for i := range songs {
+		if _, played := ui.artistRadioSongs[songs[i].Id]; played {
+			continue
+		}
+		ui.artistRadioSongs[songs[i].Id] = struct{}{}
+		unplayed = append(unplayed, songs[i])
+	}
+	return unplayed
+}
+
+// topUpArtistRadio adds more similar songs once the queue runs low, as long
+// as the song that just started came from the radio, however it was got to.
+// Playing something else stops the radio. The songs are fetched in the
+// background, and added once they arrive.
+func (ui *Ui) topUpArtistRadio(started QueueItem) {
+	if ui.artistRadioSeed == nil {
+		return
+	}
+	if _, fromRadio := ui.artistRadioSongs[started.Id]; !fromRadio {
+		ui.artistRadioSeed = nil
+		return
+	}
+	if !viper.GetBool("artistRadio.topUp") || ui.player.Remaining() > artistRadioTopUpThreshold {
+		return
+	}
+	if ui.artistRadioFetching {
+		return
+	}
+
+	ui.artistRadioFetching = true
+	seed := ui.artistRadioSeed
+	go func(connection *SubsonicConnection) {
+		songs, err := fetchSimilarSongs(connection, seed)
+		if err != nil {
+			connection.Logger.Printf("topUpArtistRadio: %s -- %s", seed.name, err.Error())
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			// the radio may have been stopped or restarted in the meantime
+			if ui.artistRadioSeed != seed {
+				return
+			}
+			ui.artistRadioFetching = false
+			if err != nil {
+				return
+			}
+
+			added := ui.newArtistRadioSongs(songs)
+			if len(added) == 0 {
+				// every similar song has been played, so play them again
+				// rather than let the music stop
+				queued := make(map[string]struct{})
+				for _, item := range ui.player.Queue[ui.player.Current:] {
+					queued[item.Id] = struct{}{}
+				}
+				for i := range songs {
+					if _, ok := queued[songs[i].Id]; !ok {
+						added = append(added, songs[i])
+					}
+				}
+			}
+
+			for i := range added {
+				ui.addSongToQueue(&added[i])
+			}
+			updateQueueList(ui.player, ui.queueList, ui.starIdList)
+		})
+	}(ui.connection)
+}
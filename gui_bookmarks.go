@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Subsonic servers keep a single bookmark per song. To keep several positions
+// in one song, all of them are listed in the bookmark's comment, and the
+// position of the bookmark itself is the most recently saved one. Anything
+// else in the comment, such as a note left by another client, is kept after
+// the positions, until the last position is deleted along with the bookmark.
+
+// a single saved position, as listed on the bookmarks page
+type bookmarkRow struct {
+	entity   SubsonicEntity
+	position float64
+}
+
+func (ui *Ui) createBookmarksPage(titleFlex *tview.Flex) *tview.Flex {
+	ui.bookmarkList = tview.NewList().ShowSecondaryText(false)
+
+	bookmarksFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.bookmarkList, 0, 1, true)
+
+	ui.bookmarkList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("refresh"):
+			ui.refreshBookmarks()
+			return nil
+		case keybind("deleteBookmark"):
+			ui.handleDeleteBookmark()
+			return nil
+		}
+		return event
+	})
+
+	return bookmarksFlex
+}
+
+func (ui *Ui) refreshBookmarks() {
+	response, err := ui.connection.GetBookmarks()
+	if err != nil {
+		ui.connection.Logger.Printf("refreshBookmarks: GetBookmarks -- %s", err.Error())
+		return
+	}
+
+	ui.bookmarkRows = nil
+	for _, bookmark := range response.Bookmarks.Bookmarks {
+		for _, position := range bookmarkPositions(bookmark) {
+			ui.bookmarkRows = append(ui.bookmarkRows, bookmarkRow{bookmark.Entry, position})
+		}
+	}
+	ui.bookmarksLoaded = true
+
+	goBackTo := ui.bookmarkList.GetCurrentItem()
+	ui.bookmarkList.Clear()
+	for _, row := range ui.bookmarkRows {
+		text := fmt.Sprintf("%s - %s @ %s", row.entity.getSongTitle(), row.entity.Artist, formatPosition(row.position))
+		ui.bookmarkList.AddItem(tview.Escape(text), "", 0, ui.makeBookmarkHandler(row))
+	}
+
+	if goBackTo < ui.bookmarkList.GetItemCount() {
+		ui.bookmarkList.SetCurrentItem(goBackTo)
+	}
+}
+
+func (ui *Ui) makeBookmarkHandler(row bookmarkRow) func() {
+	return func() {
+		item := ui.makeQueueItem(&row.entity)
+		if err := ui.player.ReplaceFrom([]QueueItem{item}, row.position); err != nil {
+			ui.connection.Logger.Printf("bookmark %s: ReplaceFrom -- %s", item.Title, err.Error())
+		}
+		updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	}
+}
+
+// songBookmarkPositions returns the positions saved in a song, and the rest
+// of its bookmark's comment
+func (ui *Ui) songBookmarkPositions(id string) ([]float64, string, error) {
+	response, err := ui.connection.GetBookmarks()
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, bookmark := range response.Bookmarks.Bookmarks {
+		if bookmark.Entry.Id == id {
+			return bookmarkPositions(bookmark), bookmarkNote(bookmark), nil
+		}
+	}
+	return nil, "", nil
+}
+
+// saveBookmarkPositions stores the positions of a song on the server, with
+// latest as the bookmark's own position and note kept after the positions in
+// the comment. No positions deletes the bookmark.
+func (ui *Ui) saveBookmarkPositions(id string, positions []float64, latest float64, note string) error {
+	if len(positions) == 0 {
+		_, err := ui.connection.DeleteBookmark(id)
+		return err
+	}
+
+	_, err := ui.connection.CreateBookmark(id, int64(latest*1000), bookmarkComment(positions, note))
+	return err
+}
+
+// bookmarkComment lists positions in a bookmark's comment, in order, with
+// note after them. bookmarkPositions and bookmarkNote read them back.
+func bookmarkComment(positions []float64, note string) string {
+	sort.Float64s(positions)
+	formatted := make([]string, 0, len(positions))
+	for _, position := range positions {
+		formatted = append(formatted, formatPosition(position))
+	}
+	if note != "" {
+		formatted = append(formatted, note)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// handleBookmarkCurrent adds the current position of the playing song to its
+// bookmarks
+func (ui *Ui) handleBookmarkCurrent() {
+	current := ui.player.CurrentItem()
+	if current == nil || current.IsRadio {
+		return
+	}
+
+	position, err := ui.player.Position()
+	if err != nil {
+		ui.connection.Logger.Printf("handleBookmarkCurrent: Position -- %s", err.Error())
+		return
+	}
+
+	positions, note, err := ui.songBookmarkPositions(current.Id)
+	if err != nil {
+		ui.connection.Logger.Printf("handleBookmarkCurrent: GetBookmarks -- %s", err.Error())
+		return
+	}
+
+	if !containsPosition(positions, position) {
+		positions = append(positions, position)
+	}
+
+	if err := ui.saveBookmarkPositions(current.Id, positions, position, note); err != nil {
+		ui.connection.Logger.Printf("handleBookmarkCurrent: CreateBookmark %s -- %s", current.Title, err.Error())
+		return
+	}
+
+	ui.connection.Logger.Printf("Bookmarked %s at %s", current.Title, formatPosition(position))
+	if ui.bookmarksLoaded {
+		ui.refreshBookmarks()
+	}
+}
+
+func (ui *Ui) handleDeleteBookmark() {
+	currentIndex := ui.bookmarkList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.bookmarkRows) {
+		return
+	}
+
+	row := ui.bookmarkRows[currentIndex]
+	positions, note, err := ui.songBookmarkPositions(row.entity.Id)
+	if err != nil {
+		ui.connection.Logger.Printf("handleDeleteBookmark: GetBookmarks -- %s", err.Error())
+		return
+	}
+
+	remaining := make([]float64, 0, len(positions))
+	for _, position := range positions {
+		if math.Abs(position-row.position) >= 1 {
+			remaining = append(remaining, position)
+		}
+	}
+
+	var latest float64
+	if len(remaining) > 0 {
+		latest = remaining[len(remaining)-1]
+	}
+
+	if err := ui.saveBookmarkPositions(row.entity.Id, remaining, latest, note); err != nil {
+		ui.connection.Logger.Printf("handleDeleteBookmark: %s -- %s", row.entity.getSongTitle(), err.Error())
+		return
+	}
+
+	ui.refreshBookmarks()
+}
+
+// bookmarkPositions returns every position saved in a bookmark, in seconds,
+// in the order they appear in the song
+func bookmarkPositions(bookmark SubsonicBookmark) []float64 {
+	positions := []float64{float64(bookmark.Position) / 1000}
+
+	for _, field := range strings.Split(bookmark.Comment, ",") {
+		position, err := parsePosition(strings.TrimSpace(field))
+		if err == nil && !containsPosition(positions, position) {
+			positions = append(positions, position)
+		}
+	}
+
+	sort.Float64s(positions)
+	return positions
+}
+
+// bookmarkNote returns whatever in a bookmark's comment isn't a position
+func bookmarkNote(bookmark SubsonicBookmark) string {
+	var note []string
+	for _, field := range strings.Split(bookmark.Comment, ",") {
+		field = strings.TrimSpace(field)
+		if _, err := parsePosition(field); err != nil && field != "" {
+			note = append(note, field)
+		}
+	}
+	return strings.Join(note, ", ")
+}
+
+// positions in comments only go down to the second, so anything closer than
+// that is the same position
+func containsPosition(positions []float64, position float64) bool {
+	for _, p := range positions {
+		if math.Abs(p-position) < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPosition formats seconds as mm:ss, or h:mm:ss for long recordings
+func formatPosition(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total%3600/60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
+
+// parsePosition is the reverse of formatPosition
+func parsePosition(text string) (float64, error) {
+	fields := strings.Split(text, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, fmt.Errorf("invalid position %q", text)
+	}
+
+	seconds := 0
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid position %q", text)
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatPosition(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0, "00:00"},
+		{59.9, "00:59"},
+		{61, "01:01"},
+		{3599, "59:59"},
+		{3600, "1:00:00"},
+		{36061, "10:01:01"},
+	}
+
+	for _, test := range tests {
+		if got := formatPosition(test.seconds); got != test.want {
+			t.Errorf("formatPosition(%v) = %q, want %q", test.seconds, got, test.want)
+		}
+		if position, err := parsePosition(test.want); err != nil || position != float64(int(test.seconds)) {
+			t.Errorf("parsePosition(%q) = %v, %v, want %v", test.want, position, err, float64(int(test.seconds)))
+		}
+	}
+}
+
+func TestParsePositionInvalid(t *testing.T) {
+	for _, text := range []string{"", "12", "1:2:3:4", "a:00", "01:-1", "great intro", "1:00 "} {
+		if position, err := parsePosition(text); err == nil {
+			t.Errorf("parsePosition(%q) = %v, want an error", text, position)
+		}
+	}
+}
+
+func TestBookmarkComment(t *testing.T) {
+	comment := bookmarkComment([]float64{3725, 90, 600}, "left off here, chapter 3")
+	if want := "01:30, 10:00, 1:02:05, left off here, chapter 3"; comment != want {
+		t.Errorf("comment is %q, want %q", comment, want)
+	}
+
+	// the bookmark's own position is the latest saved one, and is listed in
+	// the comment as well
+	bookmark := SubsonicBookmark{Position: 600000, Comment: comment}
+	if positions := bookmarkPositions(bookmark); !reflect.DeepEqual(positions, []float64{90, 600, 3725}) {
+		t.Errorf("positions are %v, want [90 600 3725]", positions)
+	}
+	if note := bookmarkNote(bookmark); note != "left off here, chapter 3" {
+		t.Errorf("note is %q, want %q", note, "left off here, chapter 3")
+	}
+}
+
+func TestBookmarkPositionsFromOtherClients(t *testing.T) {
+	// a bookmark saved by another client only has its own position, which
+	// may fall between seconds, and maybe a note
+	bookmark := SubsonicBookmark{Position: 61500, Comment: "great intro"}
+	if positions := bookmarkPositions(bookmark); !reflect.DeepEqual(positions, []float64{61.5}) {
+		t.Errorf("positions are %v, want [61.5]", positions)
+	}
+	if note := bookmarkNote(bookmark); note != "great intro" {
+		t.Errorf("note is %q, want %q", note, "great intro")
+	}
+
+	// the position is listed in the comment only to the second
+	bookmark = SubsonicBookmark{Position: 61500, Comment: "01:01, 02:00"}
+	if positions := bookmarkPositions(bookmark); !reflect.DeepEqual(positions, []float64{61.5, 120}) {
+		t.Errorf("positions are %v, want [61.5 120]", positions)
+	}
+	if note := bookmarkNote(bookmark); note != "" {
+		t.Errorf("note is %q, want none", note)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spf13/viper"
+)
+
+// size, in pixels, cover art thumbnails are requested and cached at
+const coverArtSize = 300
+
+// coverArtDir returns where the cover art thumbnails of a server are cached,
+// apart from those of any other servers and users
+func coverArtDir(connection *SubsonicConnection) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, "coverart", serverKey(connection.Host, connection.Username+connection.ApiKey))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// loadCoverArt returns the thumbnail of a cover art id, from the cache if it
+// has been fetched before. It doesn't touch the UI, so it can be called from
+// any goroutine.
+func loadCoverArt(connection *SubsonicConnection, id string) (image.Image, error) {
+	dir, err := coverArtDir(connection)
+	if err != nil {
+		return nil, err
+	}
+	// ids are opaque, so keep them from escaping the directory
+	path := filepath.Join(dir, strings.ReplaceAll(id, string(filepath.Separator), "_"))
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		// mark it as recently shown, so it is the last to be removed
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	} else {
+		data, err = connection.GetCoverArt(id, coverArtSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			connection.Logger.Printf("loadCoverArt: %s -- %s", path, err.Error())
+		}
+		// configured in megabytes
+		maxSize := int64(viper.GetInt("ui.coverArtCacheSize")) << 20
+		if err := evictCoverArt(dir, maxSize); err != nil {
+			connection.Logger.Printf("loadCoverArt: evictCoverArt -- %s", err.Error())
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// evictCoverArt removes the thumbnails shown least recently until the cover
+// art of a server fits in maxSize bytes again. 0 is no limit.
+func evictCoverArt(dir string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, entry := range entries {
+		if size <= maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= entry.Size()
+	}
+	return nil
+}
+
+func (ui *Ui) createNowPlaying() *tview.Flex {
+	mode := viper.GetString("ui.coverArt")
+	if mode == GraphicsAuto {
+		mode = detectGraphics()
+	}
+
+	ui.coverArt = NewCoverArtView(mode)
+	ui.nowPlayingText = tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter)
+
+	nowPlaying := tview.NewFlex().SetDirection(tview.FlexRow)
+	if mode != GraphicsNone {
+		nowPlaying.AddItem(ui.coverArt, 0, 1, false)
+	}
+	nowPlaying.AddItem(ui.nowPlayingText, 3, 0, false)
+	nowPlaying.SetBorder(true).
+		SetTitle("Now playing")
+
+	// escape sequences for the terminal only go out after drawing, so they
+	// don't end up in the middle of tcell's
+	ui.app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		ui.coverArt.AfterDraw(screen)
+		ui.sendClipboard()
+	})
+
+	return nowPlaying
+}
+
+// updateNowPlaying shows the current song of the queue in the now playing
+// pane
+func (ui *Ui) updateNowPlaying() {
+	if ui.player.CurrentItem() == nil {
+		ui.nowPlayingId = ""
+		ui.nowPlayingText.SetText("")
+		ui.coverArt.SetImage(nil)
+		return
+	}
+
+	current := *ui.player.CurrentItem()
+	text := "[::b]" + tview.Escape(current.Title) + "[::-]\n" + tview.Escape(current.Artist)
+	if current.Album != "" {
+		text += "\n" + tview.Escape(current.Album)
+	}
+	ui.nowPlayingText.SetText(text)
+
+	if current.CoverArt == ui.nowPlayingId {
+		return
+	}
+	ui.nowPlayingId = current.CoverArt
+
+	if current.CoverArt == "" || ui.coverArt.mode == GraphicsNone {
+		ui.coverArt.SetImage(nil)
+		return
+	}
+
+	// the previous cover stays up until this one has been fetched
+	go func(connection *SubsonicConnection, id string) {
+		img, err := loadCoverArt(connection, id)
+		if err != nil {
+			connection.Logger.Printf("updateNowPlaying: loadCoverArt %s -- %s", id, err.Error())
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			// unless another song has started in the meantime
+			if ui.nowPlayingId == id {
+				ui.coverArt.SetImage(img)
+			}
+		})
+	}(ui.connection, current.CoverArt)
+}
+
+// CoverArtView draws an image, either with one of the terminal graphics
+// protocols or with half blocks
+type CoverArtView struct {
+	*tview.Box
+	mode  string
+	image image.Image
+
+	// where the image is drawn this frame, in cells
+	x, y, cols, rows int
+	drawn            bool
+	// the half block rendering of the image, kept until the size changes
+	blocks *image.RGBA
+	// what the terminal was last sent, so it is only sent again on changes
+	sentImage                        image.Image
+	sentX, sentY, sentCols, sentRows int
+	sent                             bool
+}
+
+func NewCoverArtView(mode string) *CoverArtView {
+	return &CoverArtView{
+		Box:  tview.NewBox(),
+		mode: mode,
+	}
+}
+
+func (v *CoverArtView) SetImage(img image.Image) {
+	v.image = img
+	v.blocks = nil
+}
+
+func (v *CoverArtView) Draw(screen tcell.Screen) {
+	v.Box.DrawForSubclass(screen, v)
+	if v.image == nil {
+		return
+	}
+
+	x, y, width, height := v.GetInnerRect()
+	cols, rows := fitCells(v.image, width, height)
+	if cols == 0 {
+		return
+	}
+
+	// centre the image
+	x += (width - cols) / 2
+	y += (height - rows) / 2
+
+	if v.mode != GraphicsBlocks {
+		v.x, v.y, v.cols, v.rows = x, y, cols, rows
+		v.drawn = true
+		return
+	}
+
+	// every cell is the top half of a block coloured as the upper pixel, on
+	// a background coloured as the lower one
+	if v.blocks == nil || v.blocks.Bounds().Dx() != cols || v.blocks.Bounds().Dy() != rows*2 {
+		v.blocks = resizeImage(v.image, cols, rows*2)
+	}
+	resized := v.blocks
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			top := resized.RGBAAt(col, row*2)
+			bottom := resized.RGBAAt(col, row*2+1)
+			style := tcell.StyleDefault.
+				Foreground(tcell.NewRGBColor(int32(top.R), int32(top.G), int32(top.B))).
+				Background(tcell.NewRGBColor(int32(bottom.R), int32(bottom.G), int32(bottom.B)))
+			screen.SetContent(x+col, y+row, '▀', nil, style)
+		}
+	}
+}
+
+// AfterDraw sends the image to the terminal once tview has drawn the screen.
+// Graphics protocols draw outside of tcell, so the screen is flushed first
+// to keep tcell from drawing over the image.
+func (v *CoverArtView) AfterDraw(screen tcell.Screen) {
+	if v.mode == GraphicsBlocks || v.mode == GraphicsNone {
+		return
+	}
+
+	// Draw isn't called while the view is hidden
+	drawn := v.drawn
+	v.drawn = false
+
+	if !drawn {
+		if v.sent {
+			// the view was hidden, so whatever was drawn has to be cleared
+			v.sent = false
+			v.sentImage = nil
+			if v.mode == GraphicsKitty {
+				os.Stdout.WriteString(kittyDelete)
+			}
+			screen.Sync()
+		}
+		return
+	}
+
+	if v.sent && v.sentImage == v.image && v.sentX == v.x && v.sentY == v.y &&
+		v.sentCols == v.cols && v.sentRows == v.rows {
+		return
+	}
+
+	var sequence string
+	var err error
+	switch v.mode {
+	case GraphicsKitty:
+		sequence, err = kittyImage(v.image, v.cols, v.rows)
+		sequence = kittyDelete + sequence
+	case GraphicsITerm:
+		sequence, err = itermImage(v.image, v.cols, v.rows)
+	case GraphicsSixel:
+		sequence = sixelImage(v.image, v.cols, v.rows)
+	}
+	if err != nil {
+		return
+	}
+
+	// clear anything left of the previous image before drawing over it
+	screen.Sync()
+	// save the cursor, move it to the top left of the image, draw the
+	// image, then put the cursor back
+	os.Stdout.WriteString("\x1b7" + cursorTo(v.x, v.y) + sequence + "\x1b8")
+
+	v.sent = true
+	v.sentImage = v.image
+	v.sentX, v.sentY, v.sentCols, v.sentRows = v.x, v.y, v.cols, v.rows
+}
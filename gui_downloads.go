@@ -0,0 +1,386 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// number of songs that can wait to be downloaded at once
+const downloadQueueSize = 10000
+
+// downloadState is how a song is kept in the track cache
+type downloadState int
+
+const (
+	notDownloaded downloadState = iota
+	// downloaded ahead of playing it, and removed again once the cache
+	// fills up
+	prefetched
+	// kept for offline use
+	pinned
+)
+
+type downloadRequest struct {
+	id  string
+	url string
+	// the connection of the server the song is on, which may have been
+	// switched away from by the time it's downloaded
+	connection *SubsonicConnection
+	// prefetched songs are only downloaded ahead of playing them, so they
+	// aren't reported once done
+	prefetch bool
+	// whether url is of a transcoded stream rather than the original file
+	transcode bool
+}
+
+// startDownloads starts downloading songs queued with queueDownload in the
+// background, one at a time. Prefetched songs are about to be played, so they
+// are downloaded before any others waiting. Songs still waiting from an
+// earlier call, such as those of a server switched away from, are dropped.
+func (ui *Ui) startDownloads() {
+	if ui.downloadsStop != nil {
+		close(ui.downloadsStop)
+		ui.downloadsStop = nil
+	}
+	ui.downloadsPending = make(map[string]bool)
+	if ui.connection.Tracks == nil {
+		ui.downloadQueue = nil
+		ui.prefetchQueue = nil
+		return
+	}
+
+	ui.downloadQueue = make(chan downloadRequest, downloadQueueSize)
+	ui.prefetchQueue = make(chan downloadRequest, downloadQueueSize)
+	ui.downloadsStop = make(chan struct{})
+
+	go func(downloads chan downloadRequest, prefetches chan downloadRequest, stop chan struct{}) {
+		for {
+			var request downloadRequest
+			select {
+			case <-stop:
+				return
+			case request = <-prefetches:
+			default:
+				select {
+				case <-stop:
+					return
+				case request = <-prefetches:
+				case request = <-downloads:
+				}
+			}
+
+			err := downloadTrack(request)
+			if err == nil {
+				// the queue may be about to play songs from the cache, so
+				// those are kept along with the one just downloaded
+				var keep []string
+				ui.app.QueueUpdate(func() {
+					keep = append(ui.queuedTrackIds(), request.id)
+				})
+				if err := request.connection.Tracks.Evict(keep); err != nil {
+					request.connection.Logger.Printf("startDownloads: Evict -- %s", err.Error())
+				}
+			}
+			ui.app.QueueUpdateDraw(func() {
+				ui.downloadFinished(request, err)
+			})
+		}
+	}(ui.downloadQueue, ui.prefetchQueue, ui.downloadsStop)
+}
+
+func downloadTrack(request downloadRequest) error {
+	// the song may have been downloaded while the request waited, such as
+	// by a prefetch that a pin was queued behind
+	if request.connection.Tracks.HasDownload(request.id, request.transcode) {
+		return nil
+	}
+
+	body, err := request.connection.Download(request.url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return request.connection.Tracks.Store(request.id, body, request.transcode)
+}
+
+// queuedTrackIds returns the ids of the songs in the queue that play from the
+// track cache, including played ones that may be gone back to
+func (ui *Ui) queuedTrackIds() []string {
+	var ids []string
+	for _, item := range ui.player.Queue {
+		if !item.IsRadio && item.Uri != "" && !strings.Contains(item.Uri, "://") {
+			ids = append(ids, item.Id)
+		}
+	}
+	return ids
+}
+
+// queueDownload queues a song to be downloaded, unless it already has been
+// or is waiting to be. A song that was only prefetched, transcoded, is
+// downloaded again if the original is asked for, and a prefetch that is still
+// waiting doesn't hold up anything else asked of the song.
+func (ui *Ui) queueDownload(request downloadRequest) bool {
+	if ui.connection.Tracks.HasDownload(request.id, request.transcode) {
+		return false
+	}
+	if prefetch, pending := ui.downloadsPending[request.id]; pending && (!prefetch || request.prefetch) {
+		return false
+	}
+
+	queue := ui.downloadQueue
+	if request.prefetch {
+		queue = ui.prefetchQueue
+	}
+
+	select {
+	case queue <- request:
+	default:
+		ui.connection.Logger.Printf("queueDownload: %s -- too many downloads waiting", request.id)
+		return false
+	}
+	ui.downloadsPending[request.id] = request.prefetch
+	return true
+}
+
+// prefetchNext downloads the next few songs in the queue while the current
+// one plays, so they start playing from the track cache without waiting for
+// the server
+func (ui *Ui) prefetchNext() {
+	if ui.connection.Tracks == nil || !viper.GetBool("prefetch.enabled") {
+		return
+	}
+
+	ahead := viper.GetInt("prefetch.tracks")
+	for i := ui.player.Current + 1; i <= ui.player.Current+ahead && i < len(ui.player.Queue); i++ {
+		item := ui.player.Queue[i]
+		if item.IsRadio {
+			continue
+		}
+		// fetched as they would be streamed
+		ui.queueDownload(downloadRequest{
+			id:         item.Id,
+			url:        ui.connection.GetDownloadUrl(item.Id, true),
+			connection: ui.connection,
+			prefetch:   true,
+			transcode:  true,
+		})
+	}
+}
+
+// downloadFinished shows a song as downloaded everywhere it's listed, and
+// plays it from the track cache from now on
+func (ui *Ui) downloadFinished(request downloadRequest, err error) {
+	if request.connection != ui.connection {
+		// the song is on a server that has since been switched away from
+		return
+	}
+
+	id := request.id
+	// a pin queued behind a prefetch of the song is still waiting
+	if prefetch, ok := ui.downloadsPending[id]; ok && prefetch == request.prefetch {
+		delete(ui.downloadsPending, id)
+	}
+	if !request.prefetch && !ui.downloadsWaiting() {
+		defer ui.connection.Logger.Printf("Downloads finished")
+	}
+
+	if err != nil {
+		ui.connection.Logger.Printf("downloadFinished: Download %s -- %s", id, err.Error())
+		return
+	}
+
+	path, ok := ui.connection.Tracks.Path(id)
+	if !ok {
+		return
+	}
+	for i := range ui.player.Queue {
+		if ui.player.Queue[i].Id == id && !ui.player.Queue[i].IsRadio {
+			ui.player.Queue[i].Uri = path
+			updateQueueListItem(ui.player, ui.queueList, i, ui.starIdList)
+		}
+	}
+
+	ui.updateEntityListStars()
+	if index := ui.playlistList.GetCurrentItem(); index >= 0 && index < len(ui.playlists) {
+		for i, entity := range ui.playlists[index].Entries {
+			if entity.Id == id && i < ui.selectedPlaylist.GetItemCount() {
+				ui.selectedPlaylist.SetItemText(i, entity.getSongTitle()+downloadedText(ui.downloadState(id)), "")
+			}
+		}
+	}
+}
+
+// downloadsWaiting reports whether any songs besides prefetched ones are
+// still to be downloaded
+func (ui *Ui) downloadsWaiting() bool {
+	for _, prefetch := range ui.downloadsPending {
+		if !prefetch {
+			return true
+		}
+	}
+	return false
+}
+
+func (ui *Ui) downloadState(id string) downloadState {
+	if ui.connection.Tracks == nil || !ui.connection.Tracks.Has(id) {
+		return notDownloaded
+	}
+	if ui.connection.Tracks.IsPinned(id) {
+		return pinned
+	}
+	return prefetched
+}
+
+func (ui *Ui) isPinned(id string) bool {
+	return ui.connection.Tracks != nil && ui.connection.Tracks.IsPinned(id)
+}
+
+// downloadState tells how a queue item is kept in the track cache by whether
+// it plays from a file
+func (item QueueItem) downloadState() downloadState {
+	if item.Uri == "" || strings.Contains(item.Uri, "://") {
+		return notDownloaded
+	}
+	if item.Pinned {
+		return pinned
+	}
+	return prefetched
+}
+
+func downloadedText(state downloadState) string {
+	switch state {
+	case pinned:
+		return " [blue]↓"
+	case prefetched:
+		return " [gray]↓"
+	}
+	return ""
+}
+
+// offlineTarget returns the ids of the songs selected in the focused list of
+// the browser, queue, playlist or album pages, and a name for them
+func (ui *Ui) offlineTarget() ([]string, string) {
+	var songs []SubsonicEntity
+	var name string
+
+	switch ui.app.GetFocus() {
+	case ui.entityList:
+		entity := ui.selectedEntity()
+		if entity == nil {
+			return nil, ""
+		}
+		name = entity.Title
+		if entity.IsDirectory {
+			songs = ui.directorySongs(entity)
+		} else {
+			songs = []SubsonicEntity{*entity}
+		}
+	case ui.queueList:
+		index := ui.queueList.GetCurrentItem()
+		if index < 0 || index >= len(ui.player.Queue) || ui.player.Queue[index].IsRadio {
+			return nil, ""
+		}
+		return []string{ui.player.Queue[index].Id}, ui.player.Queue[index].Title
+	case ui.playlistList:
+		index := ui.playlistList.GetCurrentItem()
+		if index < 0 || index >= len(ui.playlists) {
+			return nil, ""
+		}
+		songs = ui.playlists[index].Entries
+		name = ui.playlists[index].Name
+	case ui.selectedPlaylist:
+		playlistIndex := ui.playlistList.GetCurrentItem()
+		if playlistIndex < 0 || playlistIndex >= len(ui.playlists) {
+			return nil, ""
+		}
+		entries := ui.playlists[playlistIndex].Entries
+		index := ui.selectedPlaylist.GetCurrentItem()
+		if index < 0 || index >= len(entries) {
+			return nil, ""
+		}
+		songs = entries[index : index+1]
+		name = entries[index].getSongTitle()
+	case ui.albumList:
+		index := ui.albumList.GetCurrentItem()
+		if index < 0 || index >= len(ui.albums) {
+			return nil, ""
+		}
+		songs = ui.albumSongs(ui.albums[index].Id)
+		name = ui.albums[index].Name
+	default:
+		return nil, ""
+	}
+
+	ids := make([]string, 0, len(songs))
+	for _, song := range songs {
+		ids = append(ids, song.Id)
+	}
+	return ids, name
+}
+
+// handlePinOffline pins the selected songs for offline use and downloads
+// them, or unpins them if they all are already. Songs that failed to download
+// are downloaded again rather than unpinned.
+func (ui *Ui) handlePinOffline() {
+	if ui.connection.Tracks == nil || !viper.GetBool("downloads.enabled") {
+		ui.connection.Logger.Printf("Downloads are disabled")
+		return
+	}
+
+	ids, name := ui.offlineTarget()
+	if len(ids) == 0 {
+		return
+	}
+
+	transcode := viper.GetBool("downloads.transcode")
+	pinned := true
+	for _, id := range ids {
+		_, pending := ui.downloadsPending[id]
+		if !ui.connection.Tracks.IsPinned(id) || (!pending && !ui.connection.Tracks.HasDownload(id, transcode)) {
+			pinned = false
+			break
+		}
+	}
+
+	if pinned {
+		if err := ui.connection.Tracks.Unpin(ids); err != nil {
+			ui.connection.Logger.Printf("handlePinOffline: Unpin %s -- %s", name, err.Error())
+			return
+		}
+		ui.connection.Logger.Printf("%s is no longer kept for offline use", name)
+		ui.updatePinned()
+		return
+	}
+
+	if err := ui.connection.Tracks.Pin(ids); err != nil {
+		ui.connection.Logger.Printf("handlePinOffline: Pin %s -- %s", name, err.Error())
+		return
+	}
+	// songs that were prefetched already are marked as kept right away
+	ui.updatePinned()
+	queued := 0
+	for _, id := range ids {
+		request := downloadRequest{
+			id:         id,
+			url:        ui.connection.GetDownloadUrl(id, transcode),
+			connection: ui.connection,
+			transcode:  transcode,
+		}
+		if ui.queueDownload(request) {
+			queued++
+		}
+	}
+	ui.connection.Logger.Printf("Keeping %s for offline use, %d songs to download", name, queued)
+}
+
+// updatePinned marks the songs in the queue and the browser by whether they
+// are kept for offline use, after pinning or unpinning some
+func (ui *Ui) updatePinned() {
+	for i := range ui.player.Queue {
+		ui.player.Queue[i].Pinned = ui.isPinned(ui.player.Queue[i].Id)
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateEntityListStars()
+}
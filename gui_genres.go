@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spf13/viper"
+)
+
+func (ui *Ui) createGenresPage(titleFlex *tview.Flex) *tview.Flex {
+	ui.genreList = tview.NewList().ShowSecondaryText(false)
+	ui.genreSongList = tview.NewList().ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+
+	genreColFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(ui.genreList, 0, 1, true).
+		AddItem(ui.genreSongList, 0, 2, false)
+
+	genresFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(genreColFlex, 0, 1, true)
+
+	ui.genreList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		ui.handleGenreSelected(index)
+	})
+
+	ui.genreList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("right"):
+			ui.app.SetFocus(ui.genreSongList)
+			return nil
+		case keybind("add"):
+			ui.handleAddGenreSongsToQueue()
+			return nil
+		case keybind("refresh"):
+			ui.refreshGenres()
+			return nil
+		}
+		return event
+	})
+
+	ui.genreSongList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("left"):
+			ui.app.SetFocus(ui.genreList)
+			return nil
+		case keybind("add"):
+			ui.handleAddGenreSongToQueue()
+			return nil
+		case keybind("star"):
+			ui.handleToggleGenreSongStar()
+			return nil
+		case keybind("addToPlaylist"):
+			ui.showAddToPlaylist(ui.selectedGenreSong(), ui.genreSongList)
+			return nil
+		case keybind("nextPage"):
+			// a short page means there is nothing after it
+			if len(ui.genreSongs) < songsByGenrePageSize {
+				return nil
+			}
+			ui.genreSongOffset += songsByGenrePageSize
+			ui.refreshGenreSongs()
+			return nil
+		case keybind("prevPage"):
+			if ui.genreSongOffset == 0 {
+				return nil
+			}
+			ui.genreSongOffset -= songsByGenrePageSize
+			if ui.genreSongOffset < 0 {
+				ui.genreSongOffset = 0
+			}
+			ui.refreshGenreSongs()
+			return nil
+		}
+		return event
+	})
+
+	return genresFlex
+}
+
+func (ui *Ui) refreshGenres() {
+	response, err := ui.connection.GetGenres()
+	if err != nil {
+		ui.connection.Logger.Printf("refreshGenres: GetGenres -- %s", err.Error())
+		return
+	}
+
+	ui.genres = response.Genres.Genres
+	ui.genresLoaded = true
+
+	ui.genreList.Clear()
+	for _, genre := range ui.genres {
+		ui.genreList.AddItem(tview.Escape(fmt.Sprintf("%s (%d)", genre.Name, genre.SongCount)), "", 0, nil)
+	}
+
+	if len(ui.genres) > 0 {
+		ui.handleGenreSelected(ui.genreList.GetCurrentItem())
+	}
+}
+
+func (ui *Ui) handleGenreSelected(index int) {
+	if index < 0 || index >= len(ui.genres) {
+		return
+	}
+
+	ui.selectedGenre = ui.genres[index].Name
+	ui.genreSongOffset = 0
+	ui.refreshGenreSongs()
+}
+
+// refreshGenreSongs fetches the current page of songs of the selected genre
+func (ui *Ui) refreshGenreSongs() {
+	response, err := ui.connection.GetSongsByGenre(ui.selectedGenre, ui.genreSongOffset)
+	if err != nil {
+		ui.connection.Logger.Printf("refreshGenreSongs: GetSongsByGenre %s -- %s", ui.selectedGenre, err.Error())
+		return
+	}
+
+	ui.genreSongs = response.SongsByGenre.Song
+
+	ui.genreSongList.Clear()
+	for _, entity := range ui.genreSongs {
+		ui.genreSongList.AddItem(ui.searchSongText(entity), "", 0,
+			makeSongHandler(ui.makeQueueItem(&entity), ui.player, ui.queueList, ui.starIdList))
+	}
+}
+
+func (ui *Ui) selectedGenreSong() *SubsonicEntity {
+	currentIndex := ui.genreSongList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.genreSongs) {
+		return nil
+	}
+	return &ui.genreSongs[currentIndex]
+}
+
+func (ui *Ui) handleAddGenreSongToQueue() {
+	entity := ui.selectedGenreSong()
+	if entity == nil {
+		return
+	}
+
+	currentIndex := ui.genreSongList.GetCurrentItem()
+	if currentIndex+1 < ui.genreSongList.GetItemCount() {
+		ui.genreSongList.SetCurrentItem(currentIndex + 1)
+	}
+
+	ui.addSongToQueue(entity)
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+// handleAddGenreSongsToQueue adds the songs shown for the selected genre
+func (ui *Ui) handleAddGenreSongsToQueue() {
+	for i := range ui.genreSongs {
+		ui.addSongToQueue(&ui.genreSongs[i])
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+func (ui *Ui) handleToggleGenreSongStar() {
+	entity := ui.selectedGenreSong()
+	if entity == nil {
+		return
+	}
+
+	ui.toggleStarred(entity.Id, ui.connection.ToggleStar)
+	ui.genreSongList.SetItemText(ui.genreSongList.GetCurrentItem(), ui.searchSongText(*entity), "")
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateEntityListStars()
+}
+
+// randomSongFilterFromConfig returns the filter set in the [random] section
+// of the config
+func randomSongFilterFromConfig() RandomSongFilter {
+	return RandomSongFilter{
+		Size:     viper.GetInt("random.size"),
+		Genre:    viper.GetString("random.genre"),
+		FromYear: viper.GetInt("random.fromYear"),
+		ToYear:   viper.GetInt("random.toYear"),
+	}
+}
+
+func (ui *Ui) createRandomSongsModal() tview.Primitive {
+	ui.randomSongsForm = tview.NewForm()
+	ui.randomSongsForm.
+		AddInputField("Genre", "", 30, nil, nil).
+		AddInputField("From year", "", 6, tview.InputFieldInteger, nil).
+		AddInputField("To year", "", 6, tview.InputFieldInteger, nil).
+		AddInputField("Count", "", 6, tview.InputFieldInteger, nil).
+		AddButton("Add", func() {
+			ui.addRandomSongsToQueue(ui.randomSongsFormFilter())
+			updateQueueList(ui.player, ui.queueList, ui.starIdList)
+			ui.hideRandomSongsForm()
+		}).
+		AddButton("Cancel", ui.hideRandomSongsForm).
+		SetCancelFunc(ui.hideRandomSongsForm)
+
+	ui.randomSongsForm.SetBorder(true).
+		SetTitle("Add random songs")
+
+	return makeModal(ui.randomSongsForm, 50, 13)
+}
+
+// showRandomSongsForm prompts for the filters of the random songs to add.
+// The form starts from the [random] config, or from the selected genre on
+// the genres page.
+func (ui *Ui) showRandomSongsForm() {
+	filter := randomSongFilterFromConfig()
+	if name, _ := ui.pages.GetFrontPage(); name == "genres" && ui.selectedGenre != "" {
+		filter.Genre = ui.selectedGenre
+	}
+
+	setFormText(ui.randomSongsForm, "Genre", filter.Genre)
+	setFormText(ui.randomSongsForm, "From year", intOrEmpty(filter.FromYear))
+	setFormText(ui.randomSongsForm, "To year", intOrEmpty(filter.ToYear))
+	setFormText(ui.randomSongsForm, "Count", intOrEmpty(filter.Size))
+
+	ui.randomSongsReturn = ui.app.GetFocus()
+	ui.randomSongsForm.SetFocus(0)
+	ui.pages.ShowPage("randomSongs")
+	ui.app.SetFocus(ui.randomSongsForm)
+}
+
+func (ui *Ui) hideRandomSongsForm() {
+	ui.pages.HidePage("randomSongs")
+	if ui.randomSongsReturn != nil {
+		ui.app.SetFocus(ui.randomSongsReturn)
+	}
+}
+
+func (ui *Ui) randomSongsFormFilter() RandomSongFilter {
+	fromYear, _ := strconv.Atoi(getFormText(ui.randomSongsForm, "From year"))
+	toYear, _ := strconv.Atoi(getFormText(ui.randomSongsForm, "To year"))
+	size, _ := strconv.Atoi(getFormText(ui.randomSongsForm, "Count"))
+
+	return RandomSongFilter{
+		Size:     size,
+		Genre:    strings.TrimSpace(getFormText(ui.randomSongsForm, "Genre")),
+		FromYear: fromYear,
+		ToYear:   toYear,
+	}
+}
+
+func getFormText(form *tview.Form, label string) string {
+	if field, ok := form.GetFormItemByLabel(label).(*tview.InputField); ok {
+		return field.GetText()
+	}
+	return ""
+}
+
+func setFormText(form *tview.Form, label string, text string) {
+	if field, ok := form.GetFormItemByLabel(label).(*tview.InputField); ok {
+		field.SetText(text)
+	}
+}
+
+func intOrEmpty(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
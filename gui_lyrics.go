@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// lyrics page, showing the lyrics of the song that is playing. Synced lyrics
+// highlight the line being sung, and keep it in view.
+
+func (ui *Ui) createLyricsPage(titleFlex *tview.Flex) *tview.Flex {
+	ui.lyricsView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(true).
+		SetWordWrap(true)
+	ui.lyricsView.SetBorder(true).
+		SetTitle("Lyrics")
+
+	lyricsFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.lyricsView, 0, 1, true)
+
+	ui.lyricsView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("refresh"):
+			ui.lyricsSongId = ""
+			ui.refreshLyrics()
+			return nil
+		}
+		return event
+	})
+
+	return lyricsFlex
+}
+
+// refreshLyrics shows the lyrics of the playing song, unless they are
+// already shown. They are fetched in the background and shown once they
+// arrive, if the song is still the one playing by then.
+func (ui *Ui) refreshLyrics() {
+	current := ui.player.CurrentItem()
+	if current == nil || current.IsRadio {
+		ui.lyricsSongId = ""
+		ui.lyrics = nil
+		ui.lyricsView.SetTitle("Lyrics")
+		ui.lyricsView.SetText("Nothing is playing")
+		return
+	}
+
+	if current.Id == ui.lyricsSongId {
+		return
+	}
+
+	ui.lyricsSongId = current.Id
+	ui.lyricsLine = -1
+	ui.lyrics = nil
+	ui.lyricsView.SetTitle(tview.Escape(current.Title + " - " + current.Artist))
+	ui.lyricsView.SetText("Loading lyrics...")
+
+	song := *current
+	go func(connection *SubsonicConnection) {
+		lyrics, err := connection.GetSongLyrics(song.Id, song.Artist, song.Title)
+		if err != nil {
+			connection.Logger.Printf("refreshLyrics: GetSongLyrics %s -- %s", song.Title, err.Error())
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			if ui.lyricsSongId == song.Id {
+				ui.showLyrics(lyrics)
+			}
+		})
+	}(ui.connection)
+}
+
+// showLyrics fills the lyrics page with the lyrics of the song it is showing
+func (ui *Ui) showLyrics(lyrics *SubsonicStructuredLyrics) {
+	ui.lyrics = lyrics
+	if lyrics == nil {
+		ui.lyricsView.SetText("No lyrics found")
+		return
+	}
+
+	// every synced line is a region, so the current one can be highlighted
+	var text strings.Builder
+	for i, line := range lyrics.Lines {
+		if lyrics.Synced {
+			fmt.Fprintf(&text, "[\"%d\"]%s[\"\"]\n", i, tview.Escape(line.Value))
+		} else {
+			text.WriteString(tview.Escape(line.Value) + "\n")
+		}
+	}
+
+	ui.lyricsView.Highlight().
+		SetText(text.String()).
+		ScrollToBeginning()
+}
+
+// updateLyricsPosition highlights the synced lyrics line being sung at
+// position seconds into the song
+func (ui *Ui) updateLyricsPosition(position float64) {
+	if ui.lyrics == nil || !ui.lyrics.Synced {
+		return
+	}
+	// the lyrics are only refreshed while the page is shown
+	if current := ui.player.CurrentItem(); current == nil || current.Id != ui.lyricsSongId {
+		return
+	}
+
+	line := syncedLineAt(ui.lyrics, position)
+	if line == ui.lyricsLine {
+		return
+	}
+
+	ui.lyricsLine = line
+	if line < 0 {
+		ui.lyricsView.Highlight().ScrollToBeginning()
+		return
+	}
+	ui.lyricsView.Highlight(strconv.Itoa(line)).ScrollToHighlight()
+}
+
+// syncedLineAt returns the index of the line of synced lyrics being sung at
+// position seconds into the song, or -1 before the first one
+func syncedLineAt(lyrics *SubsonicStructuredLyrics, position float64) int {
+	millis := int64(position*1000) + lyrics.Offset
+	line := -1
+	for i, l := range lyrics.Lines {
+		if l.Start > millis {
+			break
+		}
+		line = i
+	}
+	return line
+}
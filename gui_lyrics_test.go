@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSyncedLineAt(t *testing.T) {
+	lyrics := &SubsonicStructuredLyrics{
+		Synced: true,
+		Lines: []SubsonicLyricsLine{
+			{Start: 1000, Value: "one"},
+			{Start: 2500, Value: "two"},
+			{Start: 2500, Value: "two, sung together"},
+			{Start: 4000, Value: "three"},
+		},
+	}
+
+	tests := []struct {
+		offset   int64
+		position float64
+		want     int
+	}{
+		{0, 0, -1},
+		{0, 0.999, -1},
+		{0, 1, 0},
+		{0, 2.4, 0},
+		// lines starting together are passed on to the last of them
+		{0, 2.5, 2},
+		{0, 3.9, 2},
+		{0, 4, 3},
+		{0, 600, 3},
+		// a positive offset shows every line sooner
+		{500, 0.5, 0},
+		{500, 3.5, 3},
+		{-500, 1.2, -1},
+	}
+
+	for _, test := range tests {
+		lyrics.Offset = test.offset
+		if got := syncedLineAt(lyrics, test.position); got != test.want {
+			t.Errorf("line at %vs with offset %d is %d, want %d", test.position, test.offset, got, test.want)
+		}
+	}
+}
+
+func TestSyncedLineAtWithoutLines(t *testing.T) {
+	if got := syncedLineAt(&SubsonicStructuredLyrics{Synced: true}, 10); got != -1 {
+		t.Errorf("line of empty lyrics is %d, want -1", got)
+	}
+}
+
+func TestPlainLyrics(t *testing.T) {
+	lyrics := plainLyrics(SubsonicLyrics{Artist: "Artist", Title: "Title", Value: "\none\r\ntwo\n\nthree\n"})
+	if lyrics == nil {
+		t.Fatal("got no lyrics")
+	}
+	if lyrics.Synced || lyrics.DisplayArtist != "Artist" || lyrics.DisplayTitle != "Title" {
+		t.Errorf("got lyrics %+v, want unsynced ones of Artist - Title", lyrics)
+	}
+	var lines []string
+	for _, line := range lyrics.Lines {
+		lines = append(lines, line.Value)
+	}
+	if !reflect.DeepEqual(lines, []string{"one", "two", "", "three"}) {
+		t.Errorf("lines are %q, want one, two, a blank line and three", lines)
+	}
+
+	if lyrics := plainLyrics(SubsonicLyrics{Artist: "Artist", Title: "Title", Value: " \n"}); lyrics != nil {
+		t.Errorf("got lyrics %+v from blank ones, want none", lyrics)
+	}
+}
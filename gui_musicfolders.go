@@ -0,0 +1,117 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (ui *Ui) createMusicFolderModal() tview.Primitive {
+	ui.musicFolderList = tview.NewList().
+		ShowSecondaryText(false)
+
+	ui.musicFolderList.SetBorder(true).
+		SetTitle("Music folder")
+
+	ui.musicFolderList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			ui.hideMusicFolderPicker()
+			return nil
+		}
+		return event
+	})
+
+	return makeModal(ui.musicFolderList, 40, 12)
+}
+
+// showMusicFolderPicker lists the music folders on the server to pick the
+// one stmp shows from
+func (ui *Ui) showMusicFolderPicker() {
+	response, err := ui.connection.GetMusicFolders()
+	if err != nil {
+		ui.connection.Logger.Printf("showMusicFolderPicker: GetMusicFolders -- %s", err.Error())
+		return
+	}
+
+	ui.musicFolderList.Clear()
+	ui.musicFolderList.AddItem("All folders", "", 0, func() {
+		ui.setMusicFolder(SubsonicMusicFolder{})
+	})
+	for _, folder := range response.MusicFolders.Folders {
+		folder := folder
+		ui.musicFolderList.AddItem(tview.Escape(folder.Name), "", 0, func() {
+			ui.setMusicFolder(folder)
+		})
+		if string(folder.Id) == ui.connection.MusicFolderId {
+			ui.musicFolderList.SetCurrentItem(ui.musicFolderList.GetItemCount() - 1)
+		}
+	}
+
+	ui.musicFolderReturn = ui.app.GetFocus()
+	ui.pages.ShowPage("musicFolder")
+	ui.app.SetFocus(ui.musicFolderList)
+}
+
+func (ui *Ui) hideMusicFolderPicker() {
+	ui.pages.HidePage("musicFolder")
+	if ui.musicFolderReturn != nil {
+		ui.app.SetFocus(ui.musicFolderReturn)
+	}
+}
+
+// setMusicFolder limits stmp to a music folder, or to all of them for an
+// empty folder, and reloads everything listed from the old one
+func (ui *Ui) setMusicFolder(folder SubsonicMusicFolder) {
+	ui.hideMusicFolderPicker()
+
+	previousId, previousName := ui.connection.MusicFolderId, ui.musicFolderName
+	ui.connection.MusicFolderId = string(folder.Id)
+	ui.musicFolderName = folder.Name
+
+	if err := ui.refreshArtists(); err != nil {
+		ui.connection.Logger.Printf("setMusicFolder: refreshArtists %s -- %s", folder.Name, err.Error())
+		ui.connection.MusicFolderId, ui.musicFolderName = previousId, previousName
+		return
+	}
+	ui.currentDirectory = nil
+	ui.entityList.Clear()
+	ui.artistList.SetCurrentItem(0)
+	ui.handleArtistIndexSelected(0)
+
+	// the other pages reload the next time they are shown
+	ui.albumsLoaded = false
+	ui.albumListOffset = 0
+	ui.genresLoaded = false
+	if ui.searchQuery != "" {
+		ui.handleSearch(ui.searchQuery)
+	}
+
+	if name, _ := ui.pages.GetFrontPage(); name == "browser" {
+		ui.currentPage.SetText(ui.browserTitle())
+	}
+}
+
+// musicFolderNameOf returns the name of the music folder with the given id
+func (ui *Ui) musicFolderNameOf(id string) string {
+	name, err := fetchMusicFolderName(ui.connection, id)
+	if err != nil {
+		ui.connection.Logger.Printf("musicFolderNameOf: GetMusicFolders -- %s", err.Error())
+	}
+	return name
+}
+
+// fetchMusicFolderName returns the name of the music folder with the given
+// id, or the id if it can't be found. It doesn't touch the UI, so it can be
+// called from any goroutine.
+func fetchMusicFolderName(connection *SubsonicConnection, id string) (string, error) {
+	response, err := connection.GetMusicFolders()
+	if err != nil {
+		return id, err
+	}
+
+	for _, folder := range response.MusicFolders.Folders {
+		if string(folder.Id) == id {
+			return folder.Name, nil
+		}
+	}
+	return id, nil
+}
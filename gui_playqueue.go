@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/spf13/viper"
+)
+
+// how often the queue is checked for changes that need saving to the server
+const playQueueSyncInterval = 10 * time.Second
+
+// playQueueIds returns the ids of the songs in the queue that the server
+// knows about. Radio streams can't be part of the server's play queue.
+func (ui *Ui) playQueueIds() []string {
+	ids := make([]string, 0, len(ui.player.Queue))
+	for _, item := range ui.player.Queue {
+		if !item.IsRadio {
+			ids = append(ids, item.Id)
+		}
+	}
+	return ids
+}
+
+// playQueueState identifies the queue and its current song, to tell whether
+// either changed since they were saved
+func (ui *Ui) playQueueState() string {
+	state := strings.Join(ui.playQueueIds(), ",")
+	if item := ui.player.CurrentItem(); item != nil && !item.IsRadio {
+		state += "@" + item.Id
+	}
+	return state
+}
+
+// playQueueSnapshot is what gets saved to the server: the queue, the current
+// song and how far into it we are
+type playQueueSnapshot struct {
+	connection *SubsonicConnection
+	ids        []string
+	current    string
+	position   int64
+	state      string
+}
+
+// snapshotPlayQueue reads the queue and asks mpv for the position, so it must
+// run on the UI goroutine
+func (ui *Ui) snapshotPlayQueue() playQueueSnapshot {
+	snapshot := playQueueSnapshot{
+		connection: ui.connection,
+		ids:        ui.playQueueIds(),
+		state:      ui.playQueueState(),
+	}
+	if item := ui.player.CurrentItem(); item != nil && !item.IsRadio {
+		snapshot.current = item.Id
+		if seconds, err := ui.player.Position(); err == nil {
+			snapshot.position = int64(seconds * 1000)
+		}
+	}
+	return snapshot
+}
+
+func (snapshot playQueueSnapshot) save() error {
+	_, err := snapshot.connection.SavePlayQueue(snapshot.ids, snapshot.current, snapshot.position)
+	return err
+}
+
+// syncPlayQueue saves the queue to the server if it or its current song
+// changed since it was last saved. It runs off the UI goroutine, so only the
+// request itself is made here.
+func (ui *Ui) syncPlayQueue() {
+	if !viper.GetBool("server.syncPlayQueue") {
+		return
+	}
+
+	var snapshot playQueueSnapshot
+	changed := false
+	ui.app.QueueUpdate(func() {
+		snapshot = ui.snapshotPlayQueue()
+		changed = snapshot.state != ui.savedPlayQueue
+	})
+	if !changed {
+		return
+	}
+
+	if err := snapshot.save(); err != nil {
+		snapshot.connection.Logger.Printf("syncPlayQueue: SavePlayQueue -- %s", err.Error())
+		return
+	}
+	ui.app.QueueUpdate(func() {
+		// the server may have been switched while saving
+		if ui.connection == snapshot.connection {
+			ui.savedPlayQueue = snapshot.state
+		}
+	})
+}
+
+// savePlayQueue saves the queue, the current song and how far into it we
+// are to the server, waiting for it to be saved
+func (ui *Ui) savePlayQueue() {
+	if !viper.GetBool("server.syncPlayQueue") {
+		return
+	}
+
+	snapshot := ui.snapshotPlayQueue()
+	if err := snapshot.save(); err != nil {
+		ui.connection.Logger.Printf("savePlayQueue: SavePlayQueue -- %s", err.Error())
+		return
+	}
+	ui.savedPlayQueue = snapshot.state
+}
+
+// offerPlayQueueRestore asks whether to pick up the queue saved on the
+// server, if there is one
+func (ui *Ui) offerPlayQueueRestore() {
+	if !viper.GetBool("server.syncPlayQueue") {
+		return
+	}
+
+	playQueue, err := fetchPlayQueue(ui.connection)
+	if err != nil {
+		ui.connection.Logger.Printf("offerPlayQueueRestore: GetPlayQueue -- %s", err.Error())
+		return
+	}
+	ui.showPlayQueueRestore(playQueue)
+}
+
+// fetchPlayQueue returns the queue saved on the server, or nil if there
+// isn't one. It doesn't touch the UI, so it can be called from any goroutine.
+func fetchPlayQueue(connection *SubsonicConnection) (*SubsonicPlayQueue, error) {
+	response, err := connection.GetPlayQueue()
+	if IsNotFound(err) {
+		// some servers report a missing queue rather than an empty one
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.PlayQueue.Entries) == 0 {
+		return nil, nil
+	}
+	return &response.PlayQueue, nil
+}
+
+// showPlayQueueRestore asks whether to pick up a queue fetched with
+// fetchPlayQueue, if there is one
+func (ui *Ui) showPlayQueueRestore(playQueue *SubsonicPlayQueue) {
+	if playQueue == nil {
+		return
+	}
+
+	text := fmt.Sprintf("Restore the %d song queue", len(playQueue.Entries))
+	if playQueue.ChangedBy != "" {
+		text += " saved by " + playQueue.ChangedBy
+	}
+	if changed, err := time.Parse(time.RFC3339, playQueue.Changed); err == nil {
+		text += " on " + changed.Local().Format("Jan 2 15:04")
+	}
+	text += "?"
+
+	modal := tview.NewModal().
+		SetText(tview.Escape(text)).
+		AddButtons([]string{"Restore", "Ignore"}).
+		SetDoneFunc(func(_ int, label string) {
+			if label == "Restore" {
+				ui.restorePlayQueue(*playQueue)
+			}
+			ui.pages.RemovePage("restorePlayQueue")
+			ui.app.SetFocus(ui.pages)
+		})
+
+	ui.pages.AddPage("restorePlayQueue", modal, true, true)
+}
+
+// restorePlayQueue replaces the queue with the one saved on the server, with
+// its current song as the current item. Playback isn't started, but if the
+// current song is the first one played it picks up from the saved position.
+func (ui *Ui) restorePlayQueue(playQueue SubsonicPlayQueue) {
+	entries := playQueue.Entries
+	current := 0
+	ui.restoredSongId = ""
+	for i, entity := range entries {
+		if entity.Id == string(playQueue.Current) {
+			current = i
+			ui.restoredSongId = entity.Id
+			ui.restoredPosition = float64(playQueue.Position) / 1000
+			break
+		}
+	}
+
+	queue := make([]QueueItem, 0, len(entries))
+	for i := range entries {
+		queue = append(queue, ui.makeQueueItem(&entries[i]))
+	}
+
+	ui.player.Queue = queue
+	ui.player.Current = current
+	ui.savedPlayQueue = ui.playQueueState()
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
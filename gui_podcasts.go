@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// number of episodes shown under "Newest episodes"
+const newestEpisodeCount = 20
+
+func (ui *Ui) createPodcastsPage(titleFlex *tview.Flex) (*tview.Flex, tview.Primitive, tview.Primitive) {
+	ui.podcastList = tview.NewList().ShowSecondaryText(false)
+	ui.episodeList = tview.NewList().ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+
+	podcastColFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(ui.podcastList, 0, 1, true).
+		AddItem(ui.episodeList, 0, 2, false)
+
+	podcastsFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(podcastColFlex, 0, 1, true)
+
+	ui.podcastList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		ui.handlePodcastSelected(index)
+	})
+
+	ui.podcastList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("right"):
+			ui.app.SetFocus(ui.episodeList)
+			return nil
+		case keybind("refresh"):
+			ui.refreshPodcasts()
+			return nil
+		case keybind("newPodcastChannel"):
+			if ui.canEditPodcasts() {
+				ui.newPodcastInput.SetText("")
+				ui.pages.ShowPage("newPodcast")
+				ui.app.SetFocus(ui.newPodcastInput)
+			}
+			return nil
+		case keybind("deletePodcastChannel"):
+			// the first entry is the newest episodes, which isn't a channel
+			if ui.podcastList.GetCurrentItem() > 0 && ui.canEditPodcasts() {
+				ui.pages.ShowPage("deletePodcast")
+			}
+			return nil
+		}
+		return event
+	})
+
+	ui.episodeList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("left"):
+			ui.app.SetFocus(ui.podcastList)
+			return nil
+		case keybind("add"):
+			ui.handleAddEpisodeToQueue()
+			return nil
+		case keybind("downloadEpisode"):
+			ui.handleDownloadEpisode()
+			return nil
+		case keybind("refresh"):
+			ui.refreshPodcasts()
+			return nil
+		}
+		return event
+	})
+
+	ui.newPodcastInput = tview.NewInputField().
+		SetLabel("Feed URL: ").
+		SetFieldWidth(50)
+	ui.newPodcastInput.SetBorder(true).
+		SetTitle("New podcast")
+
+	ui.newPodcastInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			ui.handleNewPodcastChannel(strings.TrimSpace(ui.newPodcastInput.GetText()))
+		}
+		ui.pages.HidePage("newPodcast")
+		ui.app.SetFocus(ui.podcastList)
+	})
+
+	newPodcastModal := makeModal(ui.newPodcastInput, 64, 3)
+
+	deletePodcastList := tview.NewList().
+		ShowSecondaryText(false)
+
+	deletePodcastList.AddItem("Confirm", "", 0, nil)
+
+	deletePodcastList.SetBorder(true).
+		SetTitle("Confirm deletion")
+
+	deletePodcastList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			ui.handleDeletePodcastChannel()
+			ui.app.SetFocus(ui.podcastList)
+			ui.pages.HidePage("deletePodcast")
+			return nil
+		}
+		if event.Key() == tcell.KeyEscape {
+			ui.app.SetFocus(ui.podcastList)
+			ui.pages.HidePage("deletePodcast")
+			return nil
+		}
+		return event
+	})
+
+	deletePodcastModal := makeModal(deletePodcastList, 20, 3)
+
+	return podcastsFlex, newPodcastModal, deletePodcastModal
+}
+
+func (ui *Ui) refreshPodcasts() {
+	response, err := ui.connection.GetPodcasts()
+	if err != nil {
+		ui.connection.Logger.Printf("refreshPodcasts: GetPodcasts -- %s", err.Error())
+		return
+	}
+
+	ui.podcastChannels = response.Podcasts.Channels
+	ui.podcastsLoaded = true
+
+	goBackTo := ui.podcastList.GetCurrentItem()
+	ui.podcastList.Clear()
+	ui.podcastList.AddItem("Newest episodes", "", 0, nil)
+	for _, channel := range ui.podcastChannels {
+		ui.podcastList.AddItem(tview.Escape(channel.Title), "", 0, nil)
+	}
+
+	if goBackTo > 0 && goBackTo < ui.podcastList.GetItemCount() {
+		ui.podcastList.SetCurrentItem(goBackTo)
+	}
+	ui.handlePodcastSelected(ui.podcastList.GetCurrentItem())
+}
+
+// handlePodcastSelected shows the episodes of a channel. Index 0 shows the
+// newest episodes of every channel.
+func (ui *Ui) handlePodcastSelected(index int) {
+	if index == 0 {
+		response, err := ui.connection.GetNewestPodcasts(newestEpisodeCount)
+		if err != nil {
+			ui.connection.Logger.Printf("handlePodcastSelected: GetNewestPodcasts -- %s", err.Error())
+			return
+		}
+		ui.episodes = response.NewestPodcasts.Episodes
+	} else if index > 0 && index <= len(ui.podcastChannels) {
+		ui.episodes = ui.podcastChannels[index-1].Episodes
+	} else {
+		return
+	}
+
+	ui.episodeList.Clear()
+	for _, episode := range ui.episodes {
+		ui.episodeList.AddItem(ui.episodeText(episode), "", 0, ui.makeEpisodeHandler(episode))
+	}
+}
+
+func (ui *Ui) episodeText(episode SubsonicPodcastEpisode) string {
+	text := tview.Escape(episode.Title)
+	if len(episode.PublishDate) >= 10 {
+		text += " (" + episode.PublishDate[:10] + ")"
+	}
+
+	switch episode.Status {
+	case EpisodeCompleted:
+	case EpisodeDownloading:
+		text += " [yellow]downloading"
+	case EpisodeError:
+		text += " [red]download failed"
+	default:
+		text += " [gray]not downloaded"
+	}
+
+	if position, ok := ui.podcastPositions[string(episode.Id)]; ok {
+		min, sec := secondsToMinAndSec(position)
+		text += fmt.Sprintf(" [green]resume at %02d:%02d", min, sec)
+	}
+	return text
+}
+
+func (ui *Ui) episodeQueueItem(episode SubsonicPodcastEpisode) QueueItem {
+	entity := SubsonicEntity{Id: episode.StreamId}
+	return QueueItem{
+		Id:        episode.StreamId,
+		Uri:       ui.connection.GetPlayUrl(&entity),
+		Title:     episode.Title,
+		Artist:    episode.Artist,
+		Duration:  episode.Duration,
+		CoverArt:  episode.CoverArt,
+		EpisodeId: string(episode.Id),
+		Pinned:    ui.isPinned(episode.StreamId),
+	}
+}
+
+// episodes can only be streamed once the server has downloaded them
+func (ui *Ui) isEpisodePlayable(episode SubsonicPodcastEpisode) bool {
+	if episode.Status != EpisodeCompleted || episode.StreamId == "" {
+		ui.connection.Logger.Printf("%s hasn't been downloaded by the server yet", episode.Title)
+		return false
+	}
+	return true
+}
+
+func (ui *Ui) makeEpisodeHandler(episode SubsonicPodcastEpisode) func() {
+	return func() {
+		if !ui.isEpisodePlayable(episode) {
+			return
+		}
+		if err := ui.player.Replace([]QueueItem{ui.episodeQueueItem(episode)}); err != nil {
+			ui.connection.Logger.Printf("episode %s: Replace -- %s", episode.Title, err.Error())
+		}
+		updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	}
+}
+
+func (ui *Ui) selectedEpisode() *SubsonicPodcastEpisode {
+	currentIndex := ui.episodeList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.episodes) {
+		return nil
+	}
+	return &ui.episodes[currentIndex]
+}
+
+func (ui *Ui) handleAddEpisodeToQueue() {
+	episode := ui.selectedEpisode()
+	if episode == nil || !ui.isEpisodePlayable(*episode) {
+		return
+	}
+
+	currentIndex := ui.episodeList.GetCurrentItem()
+	if currentIndex+1 < ui.episodeList.GetItemCount() {
+		ui.episodeList.SetCurrentItem(currentIndex + 1)
+	}
+
+	ui.player.Queue = append(ui.player.Queue, ui.episodeQueueItem(*episode))
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+func (ui *Ui) handleDownloadEpisode() {
+	episode := ui.selectedEpisode()
+	if episode == nil || !ui.canEditPodcasts() {
+		return
+	}
+
+	if _, err := ui.connection.DownloadPodcastEpisode(string(episode.Id)); err != nil {
+		ui.connection.Logger.Printf("handleDownloadEpisode: DownloadPodcastEpisode %s -- %s", episode.Title, err.Error())
+		return
+	}
+
+	episode.Status = EpisodeDownloading
+	ui.episodeList.SetItemText(ui.episodeList.GetCurrentItem(), ui.episodeText(*episode), "")
+}
+
+// canEditPodcasts reports whether the server lets the user manage podcasts
+func (ui *Ui) canEditPodcasts() bool {
+	user := ui.currentUser()
+	if !user.PodcastRole && !user.AdminRole {
+		ui.connection.Logger.Printf("You aren't allowed to manage podcasts on this server")
+		return false
+	}
+	return true
+}
+
+func (ui *Ui) handleNewPodcastChannel(url string) {
+	if url == "" {
+		return
+	}
+
+	if _, err := ui.connection.CreatePodcastChannel(url); err != nil {
+		ui.connection.Logger.Printf("handleNewPodcastChannel: CreatePodcastChannel %s -- %s", url, err.Error())
+		return
+	}
+
+	ui.refreshPodcasts()
+}
+
+func (ui *Ui) handleDeletePodcastChannel() {
+	index := ui.podcastList.GetCurrentItem() - 1
+	if index < 0 || index >= len(ui.podcastChannels) {
+		return
+	}
+
+	channel := ui.podcastChannels[index]
+	if _, err := ui.connection.DeletePodcastChannel(string(channel.Id)); err != nil {
+		ui.connection.Logger.Printf("handleDeletePodcastChannel: DeletePodcastChannel %s -- %s", channel.Title, err.Error())
+		return
+	}
+
+	ui.podcastList.SetCurrentItem(0)
+	ui.refreshPodcasts()
+}
+
+// podcast positions are kept on disk, so episodes can be resumed after stmp
+// is restarted. Episode ids are only unique on one server, so each server
+// has its own positions.
+func podcastPositionsPath(connection *SubsonicConnection) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, "podcast-positions")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, serverKey(connection.Host, connection.Username+connection.ApiKey)+".json"), nil
+}
+
+func (ui *Ui) loadPodcastPositions() map[string]float64 {
+	positions := make(map[string]float64)
+
+	path, err := podcastPositionsPath(ui.connection)
+	if err != nil {
+		ui.connection.Logger.Printf("loadPodcastPositions: %s", err.Error())
+		return positions
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ui.connection.Logger.Printf("loadPodcastPositions: %s", err.Error())
+		}
+		return positions
+	}
+
+	if err := json.Unmarshal(data, &positions); err != nil {
+		ui.connection.Logger.Printf("loadPodcastPositions: %s -- %s", path, err.Error())
+	}
+	return positions
+}
+
+func (ui *Ui) savePodcastPositions() {
+	path, err := podcastPositionsPath(ui.connection)
+	if err != nil {
+		ui.connection.Logger.Printf("savePodcastPositions: %s", err.Error())
+		return
+	}
+
+	data, err := json.Marshal(ui.podcastPositions)
+	if err != nil {
+		ui.connection.Logger.Printf("savePodcastPositions: %s", err.Error())
+		return
+	}
+
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		ui.connection.Logger.Printf("savePodcastPositions: %s -- %s", path, err.Error())
+	}
+}
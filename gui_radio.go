@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (ui *Ui) createRadioPage(titleFlex *tview.Flex) (*tview.Flex, tview.Primitive, tview.Primitive) {
+	ui.radioList = tview.NewList().ShowSecondaryText(false)
+
+	radioFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.radioList, 0, 1, true)
+
+	ui.radioList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("add"):
+			ui.handleAddRadioStationToQueue()
+			return nil
+		case keybind("refresh"):
+			ui.refreshRadioStations()
+			return nil
+		case keybind("newRadioStation"):
+			ui.showRadioStationForm(nil)
+			return nil
+		case keybind("editRadioStation"):
+			if station := ui.selectedRadioStation(); station != nil {
+				ui.showRadioStationForm(station)
+			}
+			return nil
+		case keybind("deleteRadioStation"):
+			if ui.selectedRadioStation() != nil && ui.canEditRadioStations() {
+				ui.pages.ShowPage("deleteRadioStation")
+			}
+			return nil
+		}
+		return event
+	})
+
+	ui.radioStationForm = tview.NewForm().
+		AddInputField("Name", "", 40, nil, nil).
+		AddInputField("Stream URL", "", 40, nil, nil).
+		AddInputField("Homepage URL", "", 40, nil, nil).
+		AddButton("Save", ui.handleSaveRadioStation).
+		AddButton("Cancel", ui.hideRadioStationForm).
+		SetCancelFunc(ui.hideRadioStationForm)
+
+	ui.radioStationForm.SetBorder(true)
+
+	radioStationModal := makeModal(ui.radioStationForm, 60, 11)
+
+	deleteRadioStationList := tview.NewList().
+		ShowSecondaryText(false)
+
+	deleteRadioStationList.AddItem("Confirm", "", 0, nil)
+
+	deleteRadioStationList.SetBorder(true).
+		SetTitle("Confirm deletion")
+
+	deleteRadioStationList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			ui.handleDeleteRadioStation()
+			ui.app.SetFocus(ui.radioList)
+			ui.pages.HidePage("deleteRadioStation")
+			return nil
+		}
+		if event.Key() == tcell.KeyEscape {
+			ui.app.SetFocus(ui.radioList)
+			ui.pages.HidePage("deleteRadioStation")
+			return nil
+		}
+		return event
+	})
+
+	deleteRadioStationModal := makeModal(deleteRadioStationList, 20, 3)
+
+	return radioFlex, radioStationModal, deleteRadioStationModal
+}
+
+func (ui *Ui) refreshRadioStations() {
+	response, err := ui.connection.GetInternetRadioStations()
+	if err != nil {
+		ui.connection.Logger.Printf("refreshRadioStations: GetInternetRadioStations -- %s", err.Error())
+		return
+	}
+
+	ui.radioStations = response.RadioStations.Stations
+	ui.radioStationsLoaded = true
+
+	ui.radioList.Clear()
+	for _, station := range ui.radioStations {
+		ui.radioList.AddItem(tview.Escape(station.Name), "", 0, ui.makeRadioStationHandler(station))
+	}
+}
+
+func (ui *Ui) selectedRadioStation() *SubsonicRadioStation {
+	currentIndex := ui.radioList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.radioStations) {
+		return nil
+	}
+	return &ui.radioStations[currentIndex]
+}
+
+func radioQueueItem(station SubsonicRadioStation) QueueItem {
+	return QueueItem{
+		Id:      string(station.Id),
+		Uri:     station.StreamUrl,
+		Title:   station.Name,
+		Artist:  "Internet radio",
+		IsRadio: true,
+	}
+}
+
+func (ui *Ui) makeRadioStationHandler(station SubsonicRadioStation) func() {
+	return func() {
+		if err := ui.player.Replace([]QueueItem{radioQueueItem(station)}); err != nil {
+			ui.connection.Logger.Printf("radio station %s: Replace -- %s", station.Name, err.Error())
+		}
+		updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	}
+}
+
+func (ui *Ui) handleAddRadioStationToQueue() {
+	station := ui.selectedRadioStation()
+	if station == nil {
+		return
+	}
+
+	ui.player.Queue = append(ui.player.Queue, radioQueueItem(*station))
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+// canEditRadioStations reports whether the server lets the user manage radio
+// stations, which is an admin only action
+func (ui *Ui) canEditRadioStations() bool {
+	if !ui.currentUser().AdminRole {
+		ui.connection.Logger.Printf("Only admins can change internet radio stations")
+		return false
+	}
+	return true
+}
+
+// showRadioStationForm opens the form for a radio station. station is nil
+// when creating a new one.
+func (ui *Ui) showRadioStationForm(station *SubsonicRadioStation) {
+	if !ui.canEditRadioStations() {
+		return
+	}
+
+	ui.editedRadioStation = station
+	if station == nil {
+		ui.radioStationForm.SetTitle("New radio station")
+		station = &SubsonicRadioStation{}
+	} else {
+		ui.radioStationForm.SetTitle("Edit radio station")
+	}
+
+	setFormText(ui.radioStationForm, "Name", station.Name)
+	setFormText(ui.radioStationForm, "Stream URL", station.StreamUrl)
+	setFormText(ui.radioStationForm, "Homepage URL", station.HomePageUrl)
+
+	ui.radioStationForm.SetFocus(0)
+	ui.pages.ShowPage("radioStation")
+	ui.app.SetFocus(ui.radioStationForm)
+}
+
+func (ui *Ui) hideRadioStationForm() {
+	ui.pages.HidePage("radioStation")
+	ui.app.SetFocus(ui.radioList)
+}
+
+func (ui *Ui) handleSaveRadioStation() {
+	name := strings.TrimSpace(getFormText(ui.radioStationForm, "Name"))
+	streamUrl := strings.TrimSpace(getFormText(ui.radioStationForm, "Stream URL"))
+	homePageUrl := strings.TrimSpace(getFormText(ui.radioStationForm, "Homepage URL"))
+
+	if name == "" || streamUrl == "" {
+		ui.connection.Logger.Printf("handleSaveRadioStation: a radio station needs a name and a stream URL")
+		return
+	}
+
+	var err error
+	if ui.editedRadioStation == nil {
+		_, err = ui.connection.CreateInternetRadioStation(name, streamUrl, homePageUrl)
+	} else {
+		_, err = ui.connection.UpdateInternetRadioStation(string(ui.editedRadioStation.Id), name, streamUrl, homePageUrl)
+	}
+	if err != nil {
+		ui.connection.Logger.Printf("handleSaveRadioStation: %s -- %s", name, err.Error())
+		return
+	}
+
+	ui.hideRadioStationForm()
+	ui.refreshRadioStations()
+}
+
+func (ui *Ui) handleDeleteRadioStation() {
+	station := ui.selectedRadioStation()
+	if station == nil {
+		return
+	}
+
+	if _, err := ui.connection.DeleteInternetRadioStation(string(station.Id)); err != nil {
+		ui.connection.Logger.Printf("handleDeleteRadioStation: DeleteInternetRadioStation %s -- %s", station.Name, err.Error())
+		return
+	}
+
+	ui.refreshRadioStations()
+}
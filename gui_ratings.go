@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ratingText shows the user's rating as stars, and the average rating of
+// everyone on the server after it
+func ratingText(userRating int, averageRating float64) string {
+	var text string
+	if userRating > 0 {
+		text += " [yellow]" + strings.Repeat("★", userRating)
+	}
+	if averageRating > 0 {
+		text += fmt.Sprintf(" [gray](%.1f)", averageRating)
+	}
+	return text
+}
+
+// ratingTarget returns the id and name of the song, album or artist
+// selected in the focused list, or an empty id if nothing there can be rated
+func (ui *Ui) ratingTarget() (string, string) {
+	switch ui.app.GetFocus() {
+	case ui.queueList:
+		index := ui.queueList.GetCurrentItem()
+		if index < 0 || index >= len(ui.player.Queue) || ui.player.Queue[index].IsRadio {
+			return "", ""
+		}
+		return ui.player.Queue[index].Id, ui.player.Queue[index].Title
+	case ui.entityList:
+		entity := ui.selectedEntity()
+		if entity == nil {
+			return "", ""
+		}
+		return entity.Id, entity.Title
+	case ui.artistList:
+		index := ui.artistList.GetCurrentItem()
+		if index < 0 || index >= len(ui.artistIdList) {
+			return "", ""
+		}
+		name, _ := ui.artistList.GetItemText(index)
+		return ui.artistIdList[index], name
+	case ui.albumList:
+		index := ui.albumList.GetCurrentItem()
+		if index < 0 || index >= len(ui.albums) {
+			return "", ""
+		}
+		return ui.albums[index].Id, ui.albums[index].Name
+	case ui.searchArtistList:
+		index := ui.searchArtistList.GetCurrentItem()
+		if index < 0 || index >= len(ui.searchResult.Artists) {
+			return "", ""
+		}
+		return ui.searchResult.Artists[index].Id, ui.searchResult.Artists[index].Name
+	case ui.searchAlbumList:
+		index := ui.searchAlbumList.GetCurrentItem()
+		if index < 0 || index >= len(ui.searchResult.Albums) {
+			return "", ""
+		}
+		return ui.searchResult.Albums[index].Id, ui.searchResult.Albums[index].Name
+	case ui.searchSongList:
+		if entity := ui.selectedSearchSong(); entity != nil {
+			return entity.Id, entity.getSongTitle()
+		}
+	case ui.genreSongList:
+		if entity := ui.selectedGenreSong(); entity != nil {
+			return entity.Id, entity.getSongTitle()
+		}
+	}
+	return "", ""
+}
+
+// handleRate rates the selected song, album or artist from 1 to 5 stars, or
+// clears its rating when rating is 0
+func (ui *Ui) handleRate(rating int) {
+	id, name := ui.ratingTarget()
+	if id == "" {
+		return
+	}
+
+	if _, err := ui.connection.SetRating(id, rating); err != nil {
+		ui.connection.Logger.Printf("handleRate: SetRating %s -- %s", name, err.Error())
+		return
+	}
+
+	ui.applyRating(id, rating)
+}
+
+// applyRating records a new rating everywhere the rated item is shown, and
+// redraws those rows
+func (ui *Ui) applyRating(id string, rating int) {
+	for i := range ui.player.Queue {
+		if ui.player.Queue[i].Id == id {
+			ui.player.Queue[i].UserRating = rating
+			updateQueueListItem(ui.player, ui.queueList, i, ui.starIdList)
+		}
+	}
+
+	if ui.currentDirectory != nil {
+		for i := range ui.currentDirectory.Entities {
+			if ui.currentDirectory.Entities[i].Id == id {
+				ui.currentDirectory.Entities[i].UserRating = rating
+			}
+		}
+		ui.updateEntityListStars()
+	}
+
+	for i := range ui.albums {
+		if ui.albums[i].Id == id {
+			ui.albums[i].UserRating = rating
+			ui.albumList.SetItemText(i, ui.albumListText(ui.albums[i]), "")
+		}
+	}
+
+	for i := range ui.searchResult.Artists {
+		if ui.searchResult.Artists[i].Id == id {
+			ui.searchResult.Artists[i].UserRating = rating
+			ui.searchArtistList.SetItemText(i, ui.searchArtistText(ui.searchResult.Artists[i]), "")
+		}
+	}
+	for i := range ui.searchResult.Albums {
+		if ui.searchResult.Albums[i].Id == id {
+			ui.searchResult.Albums[i].UserRating = rating
+			ui.searchAlbumList.SetItemText(i, ui.searchAlbumText(ui.searchResult.Albums[i]), "")
+		}
+	}
+	for i := range ui.searchResult.Songs {
+		if ui.searchResult.Songs[i].Id == id {
+			ui.searchResult.Songs[i].UserRating = rating
+			ui.searchSongList.SetItemText(i, ui.searchSongText(ui.searchResult.Songs[i]), "")
+		}
+	}
+
+	for i := range ui.genreSongs {
+		if ui.genreSongs[i].Id == id {
+			ui.genreSongs[i].UserRating = rating
+			ui.genreSongList.SetItemText(i, ui.searchSongText(ui.genreSongs[i]), "")
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestRatingText(t *testing.T) {
+	tests := []struct {
+		userRating    int
+		averageRating float64
+		want          string
+	}{
+		{0, 0, ""},
+		{3, 0, " [yellow]★★★"},
+		{0, 4.26, " [gray](4.3)"},
+		{5, 3.5, " [yellow]★★★★★ [gray](3.5)"},
+		{1, 1, " [yellow]★ [gray](1.0)"},
+	}
+
+	for _, test := range tests {
+		if got := ratingText(test.userRating, test.averageRating); got != test.want {
+			t.Errorf("ratingText(%d, %v) = %q, want %q", test.userRating, test.averageRating, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// search page, showing search3 results split into artist, album and song
+// sections. Each section is paged on its own.
+
+func (ui *Ui) createSearchPage(titleFlex *tview.Flex) *tview.Flex {
+	ui.searchInput = tview.NewInputField().
+		SetLabel("Search library:")
+	ui.searchArtistList = tview.NewList().ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	ui.searchAlbumList = tview.NewList().ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	ui.searchSongList = tview.NewList().ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+
+	ui.searchArtistList.SetBorder(true)
+	ui.searchAlbumList.SetBorder(true)
+	ui.searchSongList.SetBorder(true)
+	ui.updateSearchTitles()
+
+	resultsFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(ui.searchArtistList, 0, 1, false).
+		AddItem(ui.searchAlbumList, 0, 1, false).
+		AddItem(ui.searchSongList, 0, 2, false)
+
+	searchFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.searchInput, 1, 0, true).
+		AddItem(resultsFlex, 0, 1, false)
+
+	ui.searchInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			ui.handleSearch(ui.searchInput.GetText())
+		}
+		ui.app.SetFocus(ui.searchSongList)
+	})
+
+	// left and right move between the sections, in the same order as they
+	// are drawn
+	sections := []*tview.List{ui.searchArtistList, ui.searchAlbumList, ui.searchSongList}
+	for i, list := range sections {
+		var left, right *tview.List
+		if i > 0 {
+			left = sections[i-1]
+		}
+		if i+1 < len(sections) {
+			right = sections[i+1]
+		}
+
+		list := list
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch keyName(event) {
+			case keybind("left"):
+				if left != nil {
+					ui.app.SetFocus(left)
+				}
+				return nil
+			case keybind("right"):
+				if right != nil {
+					ui.app.SetFocus(right)
+				}
+				return nil
+			case keybind("search"):
+				ui.app.SetFocus(ui.searchInput)
+				return nil
+			case keybind("nextPage"):
+				ui.handleSearchPage(list, 1)
+				return nil
+			case keybind("prevPage"):
+				ui.handleSearchPage(list, -1)
+				return nil
+			case keybind("add"):
+				ui.handleAddSearchResultToQueue(list)
+				return nil
+			case keybind("star"):
+				ui.handleToggleSearchResultStar(list)
+				return nil
+			case keybind("addToPlaylist"):
+				if list == ui.searchSongList {
+					ui.showAddToPlaylist(ui.selectedSearchSong(), list)
+				}
+				return nil
+			}
+			return event
+		})
+	}
+
+	return searchFlex
+}
+
+// handleSearch starts a new search, from the first page of every section
+func (ui *Ui) handleSearch(query string) {
+	ui.searchQuery = query
+	ui.searchArtistOffset = 0
+	ui.searchAlbumOffset = 0
+	ui.searchSongOffset = 0
+	ui.runSearch()
+}
+
+// handleSearchPage moves the section shown in list forward or back a page
+func (ui *Ui) handleSearchPage(list *tview.List, direction int) {
+	var offset *int
+	var count int
+	switch list {
+	case ui.searchArtistList:
+		offset, count = &ui.searchArtistOffset, len(ui.searchResult.Artists)
+	case ui.searchAlbumList:
+		offset, count = &ui.searchAlbumOffset, len(ui.searchResult.Albums)
+	default:
+		offset, count = &ui.searchSongOffset, len(ui.searchResult.Songs)
+	}
+
+	// a short page means there is nothing after it
+	if direction > 0 && count < searchPageSize {
+		return
+	}
+	if direction < 0 && *offset == 0 {
+		return
+	}
+
+	*offset += direction * searchPageSize
+	if *offset < 0 {
+		*offset = 0
+	}
+	ui.runSearch()
+}
+
+func (ui *Ui) runSearch() {
+	if ui.searchQuery == "" {
+		return
+	}
+
+	response, err := ui.connection.Search3(ui.searchQuery, ui.searchArtistOffset, ui.searchAlbumOffset, ui.searchSongOffset)
+	if err != nil {
+		ui.connection.Logger.Printf("runSearch: Search3 %s -- %s", ui.searchQuery, err.Error())
+		return
+	}
+	ui.showSearchResult(response.SearchResult)
+}
+
+// showSearchResult lists a page of search results, at the current offsets
+func (ui *Ui) showSearchResult(result SubsonicSearchResult) {
+	ui.searchResult = result
+	ui.updateSearchTitles()
+
+	ui.searchArtistList.Clear()
+	for _, artist := range ui.searchResult.Artists {
+		ui.searchArtistList.AddItem(ui.searchArtistText(artist), "", 0, ui.makePlayArtistHandler(artist.Id))
+	}
+
+	ui.searchAlbumList.Clear()
+	for _, album := range ui.searchResult.Albums {
+		ui.searchAlbumList.AddItem(ui.searchAlbumText(album), "", 0, ui.makePlayAlbumHandler(album.Id))
+	}
+
+	ui.searchSongList.Clear()
+	for _, entity := range ui.searchResult.Songs {
+		title := ui.searchSongText(entity)
+		ui.searchSongList.AddItem(title, "", 0,
+			makeSongHandler(ui.makeQueueItem(&entity), ui.player, ui.queueList, ui.starIdList))
+	}
+}
+
+func (ui *Ui) updateSearchTitles() {
+	ui.searchArtistList.SetTitle(searchSectionTitle("Artists", ui.searchArtistOffset, len(ui.searchResult.Artists)))
+	ui.searchAlbumList.SetTitle(searchSectionTitle("Albums", ui.searchAlbumOffset, len(ui.searchResult.Albums)))
+	ui.searchSongList.SetTitle(searchSectionTitle("Songs", ui.searchSongOffset, len(ui.searchResult.Songs)))
+}
+
+func searchSectionTitle(name string, offset int, count int) string {
+	if count == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s %d-%d", name, offset+1, offset+count)
+}
+
+func (ui *Ui) searchArtistText(artist SubsonicArtist) string {
+	return tview.Escape(artist.Name) + ratingText(artist.UserRating, artist.AverageRating) + starText(artist.Id, ui.starIdList)
+}
+
+func (ui *Ui) searchAlbumText(album SubsonicAlbum) string {
+	return tview.Escape(album.Name+" - "+album.Artist) + ratingText(album.UserRating, album.AverageRating) + starText(album.Id, ui.starIdList)
+}
+
+func (ui *Ui) searchSongText(entity SubsonicEntity) string {
+	return tview.Escape(entity.getSongTitle()+" - "+entity.Artist) + ratingText(entity.UserRating, entity.AverageRating) + starText(entity.Id, ui.starIdList)
+}
+
+func starText(id string, starredItems map[string]struct{}) string {
+	if _, hasStar := starredItems[id]; hasStar {
+		return " [red]♥"
+	}
+	return ""
+}
+
+func (ui *Ui) selectedSearchSong() *SubsonicEntity {
+	currentIndex := ui.searchSongList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.searchResult.Songs) {
+		return nil
+	}
+	return &ui.searchResult.Songs[currentIndex]
+}
+
+func (ui *Ui) handleAddSearchResultToQueue(list *tview.List) {
+	currentIndex := list.GetCurrentItem()
+	if currentIndex < 0 {
+		return
+	}
+
+	switch list {
+	case ui.searchArtistList:
+		if currentIndex >= len(ui.searchResult.Artists) {
+			return
+		}
+		for _, albumId := range ui.artistAlbumIds(ui.searchResult.Artists[currentIndex].Id) {
+			ui.addAlbumToQueue(albumId)
+		}
+	case ui.searchAlbumList:
+		if currentIndex >= len(ui.searchResult.Albums) {
+			return
+		}
+		ui.addAlbumToQueue(ui.searchResult.Albums[currentIndex].Id)
+	default:
+		if currentIndex >= len(ui.searchResult.Songs) {
+			return
+		}
+		ui.addSongToQueue(&ui.searchResult.Songs[currentIndex])
+	}
+
+	if currentIndex+1 < list.GetItemCount() {
+		list.SetCurrentItem(currentIndex + 1)
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+func (ui *Ui) handleToggleSearchResultStar(list *tview.List) {
+	currentIndex := list.GetCurrentItem()
+	if currentIndex < 0 {
+		return
+	}
+
+	var text string
+	switch list {
+	case ui.searchArtistList:
+		if currentIndex >= len(ui.searchResult.Artists) {
+			return
+		}
+		artist := ui.searchResult.Artists[currentIndex]
+		ui.toggleStarred(artist.Id, ui.connection.ToggleArtistStar)
+		text = ui.searchArtistText(artist)
+	case ui.searchAlbumList:
+		if currentIndex >= len(ui.searchResult.Albums) {
+			return
+		}
+		album := ui.searchResult.Albums[currentIndex]
+		ui.toggleStarred(album.Id, ui.connection.ToggleAlbumStar)
+		text = ui.searchAlbumText(album)
+	default:
+		if currentIndex >= len(ui.searchResult.Songs) {
+			return
+		}
+		entity := ui.searchResult.Songs[currentIndex]
+		ui.toggleStarred(entity.Id, ui.connection.ToggleStar)
+		text = ui.searchSongText(entity)
+	}
+
+	list.SetItemText(currentIndex, text, "")
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateEntityListStars()
+}
+
+// toggleStarred stars or unstars id on the server with toggle, and records
+// the new state in the star list once the server has
+func (ui *Ui) toggleStarred(id string, toggle func(string, map[string]struct{}) (*SubsonicResponse, error)) {
+	// If the item is already in the star list, remove it
+	_, remove := ui.starIdList[id]
+
+	if _, err := toggle(id, ui.starIdList); err != nil {
+		ui.connection.Logger.Printf("toggleStarred: %s -- %s", id, err.Error())
+		action := "star"
+		if remove {
+			action = "unstar"
+		}
+		ui.showError(fmt.Sprintf("Couldn't %s it: %s", action, err.Error()))
+		return
+	}
+
+	if remove {
+		delete(ui.starIdList, id)
+	} else {
+		ui.starIdList[id] = struct{}{}
+	}
+}
+
+// artistAlbumIds returns the ids of all of the albums of an artist
+func (ui *Ui) artistAlbumIds(artistId string) []string {
+	response, err := ui.connection.GetArtist(artistId)
+	if err != nil {
+		ui.connection.Logger.Printf("artistAlbumIds: GetArtist %s -- %s", artistId, err.Error())
+		return nil
+	}
+
+	albumIds := make([]string, 0, len(response.Artist.Albums))
+	for _, album := range response.Artist.Albums {
+		albumIds = append(albumIds, album.Id)
+	}
+	return albumIds
+}
+
+// playAlbums replaces the queue with the songs of the given albums
+func (ui *Ui) playAlbums(albumIds ...string) {
+	var items []QueueItem
+	for _, albumId := range albumIds {
+		response, err := ui.connection.GetAlbum(albumId)
+		if err != nil {
+			ui.connection.Logger.Printf("playAlbums: GetAlbum %s -- %s", albumId, err.Error())
+			continue
+		}
+
+		sort.Sort(response.Album.Songs)
+		for _, e := range response.Album.Songs {
+			items = append(items, ui.makeQueueItem(&e))
+		}
+	}
+
+	if err := ui.player.Replace(items); err != nil {
+		ui.connection.Logger.Printf("playAlbums: Replace -- %s", err.Error())
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+}
+
+func (ui *Ui) makePlayAlbumHandler(albumId string) func() {
+	return func() {
+		ui.playAlbums(albumId)
+	}
+}
+
+func (ui *Ui) makePlayArtistHandler(artistId string) func() {
+	return func() {
+		ui.playAlbums(ui.artistAlbumIds(artistId)...)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spf13/viper"
+)
+
+func (ui *Ui) createServerModal() tview.Primitive {
+	ui.serverList = tview.NewList().
+		ShowSecondaryText(false)
+
+	ui.serverList.SetBorder(true).
+		SetTitle("Server")
+
+	ui.serverList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			ui.hideServerPicker()
+			return nil
+		}
+		return event
+	})
+
+	return makeModal(ui.serverList, 40, 12)
+}
+
+// showServerPicker lists the configured servers to switch between
+func (ui *Ui) showServerPicker() {
+	ui.serverList.Clear()
+	for _, name := range serverProfileNames() {
+		name := name
+		ui.serverList.AddItem(tview.Escape(name), "", 0, func() {
+			ui.switchServer(name)
+		})
+		if name == ui.connection.ProfileName {
+			ui.serverList.SetCurrentItem(ui.serverList.GetItemCount() - 1)
+		}
+	}
+
+	ui.serverReturn = ui.app.GetFocus()
+	ui.pages.ShowPage("server")
+	ui.app.SetFocus(ui.serverList)
+}
+
+func (ui *Ui) hideServerPicker() {
+	ui.pages.HidePage("server")
+	if ui.serverReturn != nil {
+		ui.app.SetFocus(ui.serverReturn)
+	}
+}
+
+// switchServer connects to another server, and replaces everything loaded
+// from the current one with what's on the new one. Saving the queue to the
+// current server and connecting may wait for the server, a password command
+// or the keyring, so it's done in the background. The current server is kept
+// if the new one can't be reached.
+func (ui *Ui) switchServer(name string) {
+	ui.hideServerPicker()
+	if name == ui.connection.ProfileName {
+		return
+	}
+
+	logger := ui.connection.Logger
+	if ui.switchingServer {
+		logger.Printf("Already switching servers")
+		return
+	}
+	ui.switchingServer = true
+	logger.Printf("Connecting to %s", name)
+
+	// the queue belongs to the current server, so it's left there
+	syncPlayQueue := viper.GetBool("server.syncPlayQueue")
+	snapshot := ui.snapshotPlayQueue()
+	request := serverRequest{
+		name:          name,
+		logger:        logger,
+		tagBrowsing:   ui.tagBrowsing,
+		searchQuery:   ui.searchQuery,
+		syncPlayQueue: syncPlayQueue,
+	}
+	go func() {
+		if syncPlayQueue {
+			if err := snapshot.save(); err != nil {
+				logger.Printf("switchServer: SavePlayQueue -- %s", err.Error())
+			}
+		}
+
+		server, err := connectAndLoad(request)
+		ui.app.QueueUpdateDraw(func() {
+			ui.switchingServer = false
+			if err != nil {
+				logger.Printf("switchServer: %s -- %s", name, err.Error())
+				return
+			}
+			ui.showServer(server)
+		})
+	}()
+}
+
+// serverRequest is what connectAndLoad needs to know of the UI, read before
+// it starts
+type serverRequest struct {
+	name          string
+	logger        Logger
+	tagBrowsing   bool
+	searchQuery   string
+	syncPlayQueue bool
+}
+
+// loadedServer is everything shown of a server once it's switched to, with
+// the request it was loaded for
+type loadedServer struct {
+	serverRequest
+	connection   *SubsonicConnection
+	clientConfig ClientConfig
+	indexes      []SubsonicIndex
+	playlists    []SubsonicPlaylist
+	// these are left empty if they can't be fetched, without failing the
+	// switch
+	starred         map[string]struct{}
+	musicFolderName string
+	firstArtist     *SubsonicDirectory
+	searchResult    *SubsonicSearchResult
+	playQueue       *SubsonicPlayQueue
+}
+
+// connectAndLoad connects to a server, and loads everything that's shown
+// once it's switched to. It doesn't touch the UI, so it can be called from
+// any goroutine.
+func connectAndLoad(request serverRequest) (*loadedServer, error) {
+	logger := request.logger
+	connection, clientConfig, err := connectToServer(request.name, logger)
+	if err != nil {
+		return nil, fmt.Errorf("connectToServer: %w", err)
+	}
+	server := &loadedServer{serverRequest: request, connection: connection, clientConfig: clientConfig}
+
+	if request.tagBrowsing {
+		response, err := connection.GetArtists()
+		if err != nil {
+			return nil, fmt.Errorf("GetArtists: %w", err)
+		}
+		server.indexes = response.Artists.Index
+	} else {
+		response, err := connection.GetIndexes()
+		if err != nil {
+			return nil, fmt.Errorf("GetIndexes: %w", err)
+		}
+		server.indexes = response.Indexes.Index
+	}
+	playlistResponse, err := connection.GetPlaylists()
+	if err != nil {
+		return nil, fmt.Errorf("GetPlaylists: %w", err)
+	}
+	server.playlists = playlistResponse.Playlists.Playlists
+
+	if server.starred, err = fetchStarred(connection); err != nil {
+		logger.Printf("connectAndLoad: GetStarred -- %s", err.Error())
+	}
+	if connection.MusicFolderId != "" {
+		if server.musicFolderName, err = fetchMusicFolderName(connection, connection.MusicFolderId); err != nil {
+			logger.Printf("connectAndLoad: GetMusicFolders -- %s", err.Error())
+		}
+	}
+	if len(server.indexes) > 0 && len(server.indexes[0].Artists) > 0 {
+		artistId := server.indexes[0].Artists[0].Id
+		if server.firstArtist, err = fetchDirectory(connection, artistId, request.tagBrowsing); err != nil {
+			logger.Printf("connectAndLoad: %s -- %s", artistId, err.Error())
+		}
+	}
+	if request.searchQuery != "" {
+		response, err := connection.Search3(request.searchQuery, 0, 0, 0)
+		if err != nil {
+			logger.Printf("connectAndLoad: Search3 %s -- %s", request.searchQuery, err.Error())
+		} else {
+			server.searchResult = &response.SearchResult
+		}
+	}
+	if request.syncPlayQueue {
+		if server.playQueue, err = fetchPlayQueue(connection); err != nil {
+			logger.Printf("connectAndLoad: GetPlayQueue -- %s", err.Error())
+		}
+	}
+	return server, nil
+}
+
+// showServer replaces everything loaded from the current server with what
+// was loaded from a newly connected one. The queue of the current server was
+// saved to it, if the queue is synced, and is cleared.
+func (ui *Ui) showServer(server *loadedServer) {
+	connection := server.connection
+	logger := connection.Logger
+
+	ui.savePodcastPositions()
+	if len(ui.player.Queue) > 0 {
+		if viper.GetBool("server.syncPlayQueue") {
+			logger.Printf("Cleared the queue, which was saved to %s", ui.connection.ProfileName)
+		} else {
+			logger.Printf("Cleared the queue of %s", ui.connection.ProfileName)
+		}
+	}
+	if err := ui.player.Clear(); err != nil {
+		logger.Printf("switchServer: Clear -- %s", err.Error())
+	}
+	ui.savedPlayQueue = ""
+	ui.restoredSongId = ""
+	ui.artistRadioSeed = nil
+
+	ui.connection = connection
+	ui.podcastPositions = ui.loadPodcastPositions()
+	// the new server may keep downloads even if the old one didn't
+	ui.startDownloads()
+	ui.player.Configure(server.clientConfig)
+	ui.streamingProfiles[ui.streamingProfile].apply(connection)
+
+	ui.user = nil
+	ui.starIdList = make(map[string]struct{})
+	for id := range server.starred {
+		ui.starIdList[id] = struct{}{}
+	}
+	updateQueueList(ui.player, ui.queueList, ui.starIdList)
+	ui.updateNowPlaying()
+
+	ui.musicFolderName = server.musicFolderName
+	// the artists and search results were loaded for these, which may have
+	// been changed while connecting
+	ui.tagBrowsing = server.tagBrowsing
+	ui.searchQuery = server.searchQuery
+	ui.setArtists(server.indexes)
+	ui.currentDirectory = nil
+	ui.entityList.Clear()
+	ui.artistList.SetCurrentItem(0)
+	if server.firstArtist != nil {
+		ui.showFetchedDirectory(server.firstArtist)
+	}
+
+	ui.playlists = server.playlists
+	ui.playlistList.Clear()
+	ui.addToPlaylistList.Clear()
+	for _, playlist := range ui.playlists {
+		ui.playlistList.AddItem(playlist.Name, "", 0, nil)
+		ui.addToPlaylistList.AddItem(playlist.Name, "", 0, nil)
+	}
+	ui.selectedPlaylist.Clear()
+
+	// the other pages reload the next time they are shown
+	ui.albumsLoaded = false
+	ui.albumListOffset = 0
+	ui.genresLoaded = false
+	ui.radioStationsLoaded = false
+	ui.podcastsLoaded = false
+	ui.bookmarksLoaded = false
+	ui.sharesLoaded = false
+	ui.searchArtistOffset = 0
+	ui.searchAlbumOffset = 0
+	ui.searchSongOffset = 0
+	if server.searchResult != nil {
+		ui.showSearchResult(*server.searchResult)
+	}
+
+	ui.pages.SwitchToPage("browser")
+	ui.currentPage.SetText(ui.browserTitle())
+	ui.app.SetFocus(ui.artistList)
+
+	logger.Printf("Connected to %s (%s)", server.name, connection.Host)
+	ui.showPlayQueueRestore(server.playQueue)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (ui *Ui) createSharesPage(titleFlex *tview.Flex) (*tview.Flex, tview.Primitive, tview.Primitive) {
+	ui.shareList = tview.NewList().ShowSecondaryText(false)
+
+	sharesFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleFlex, 1, 0, false).
+		AddItem(ui.shareList, 0, 1, true)
+
+	ui.shareList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch keyName(event) {
+		case keybind("refresh"):
+			ui.refreshShares()
+			return nil
+		case keybind("deleteShare"):
+			if ui.selectedShare() != nil {
+				ui.pages.ShowPage("deleteShare")
+			}
+			return nil
+		}
+		return event
+	})
+
+	ui.shareForm = tview.NewForm().
+		AddInputField("Description", "", 40, nil, nil).
+		AddInputField("Expires in days", "", 6, tview.InputFieldInteger, nil).
+		AddButton("Share", ui.handleCreateShare).
+		AddButton("Cancel", ui.hideShareForm).
+		SetCancelFunc(ui.hideShareForm)
+
+	ui.shareForm.SetBorder(true)
+
+	shareModal := makeModal(ui.shareForm, 60, 9)
+
+	deleteShareList := tview.NewList().
+		ShowSecondaryText(false)
+
+	deleteShareList.AddItem("Confirm", "", 0, nil)
+
+	deleteShareList.SetBorder(true).
+		SetTitle("Confirm deletion")
+
+	deleteShareList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			ui.handleDeleteShare()
+			ui.app.SetFocus(ui.shareList)
+			ui.pages.HidePage("deleteShare")
+			return nil
+		}
+		if event.Key() == tcell.KeyEscape {
+			ui.app.SetFocus(ui.shareList)
+			ui.pages.HidePage("deleteShare")
+			return nil
+		}
+		return event
+	})
+
+	deleteShareModal := makeModal(deleteShareList, 20, 3)
+
+	return sharesFlex, shareModal, deleteShareModal
+}
+
+// refreshShares fetches the shares in the background, and lists them once
+// they arrive
+func (ui *Ui) refreshShares() {
+	go func(connection *SubsonicConnection) {
+		response, err := connection.GetShares()
+		if err != nil {
+			connection.Logger.Printf("refreshShares: GetShares -- %s", err.Error())
+			return
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			// the server may have been switched while fetching
+			if ui.connection != connection {
+				return
+			}
+			ui.showShares(response.Shares.Shares)
+		})
+	}(ui.connection)
+}
+
+// showShares lists the shares fetched from the server
+func (ui *Ui) showShares(shares []SubsonicShare) {
+	ui.shares = shares
+	ui.sharesLoaded = true
+
+	ui.shareList.Clear()
+	for _, share := range ui.shares {
+		ui.shareList.AddItem(shareText(share), "", 0, ui.makeShareHandler(share))
+	}
+}
+
+func shareText(share SubsonicShare) string {
+	text := share.Description
+	if text == "" && len(share.Entries) > 0 {
+		text = share.Entries[0].getSongTitle()
+		if len(share.Entries) > 1 {
+			text += fmt.Sprintf(" and %d more", len(share.Entries)-1)
+		}
+	}
+	text += " - " + share.Url
+
+	if expires, err := time.Parse(time.RFC3339, share.Expires); err == nil {
+		text += " (expires " + expires.Local().Format("Jan 2 2006") + ")"
+	}
+	if share.VisitCount > 0 {
+		text += fmt.Sprintf(" [%d visits]", share.VisitCount)
+	}
+	return tview.Escape(text)
+}
+
+// selecting a share copies its link again
+func (ui *Ui) makeShareHandler(share SubsonicShare) func() {
+	return func() {
+		ui.copyToClipboard(share.Url)
+		ui.connection.Logger.Printf("Copied %s to the clipboard", share.Url)
+	}
+}
+
+func (ui *Ui) selectedShare() *SubsonicShare {
+	currentIndex := ui.shareList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(ui.shares) {
+		return nil
+	}
+	return &ui.shares[currentIndex]
+}
+
+// shareTarget returns the id and name of what is selected in the focused
+// list of the browser, queue or playlist pages, or an empty id if nothing
+// there can be shared
+func (ui *Ui) shareTarget() (string, string) {
+	switch ui.app.GetFocus() {
+	case ui.queueList:
+		index := ui.queueList.GetCurrentItem()
+		if index < 0 || index >= len(ui.player.Queue) || ui.player.Queue[index].IsRadio {
+			return "", ""
+		}
+		return ui.player.Queue[index].Id, ui.player.Queue[index].Title
+	case ui.entityList:
+		entity := ui.selectedEntity()
+		if entity == nil {
+			return "", ""
+		}
+		return entity.Id, entity.Title
+	case ui.playlistList:
+		index := ui.playlistList.GetCurrentItem()
+		if index < 0 || index >= len(ui.playlists) {
+			return "", ""
+		}
+		return string(ui.playlists[index].Id), ui.playlists[index].Name
+	case ui.selectedPlaylist:
+		playlistIndex := ui.playlistList.GetCurrentItem()
+		if playlistIndex < 0 || playlistIndex >= len(ui.playlists) {
+			return "", ""
+		}
+		entries := ui.playlists[playlistIndex].Entries
+		index := ui.selectedPlaylist.GetCurrentItem()
+		if index < 0 || index >= len(entries) {
+			return "", ""
+		}
+		return entries[index].Id, entries[index].getSongTitle()
+	}
+	return "", ""
+}
+
+// showShareForm prompts for the description and expiry of a share of the
+// selection
+func (ui *Ui) showShareForm() {
+	id, name := ui.shareTarget()
+	if id == "" {
+		return
+	}
+
+	ui.shareId = id
+	ui.shareReturn = ui.app.GetFocus()
+	ui.shareForm.SetTitle(tview.Escape("Share " + name))
+	setFormText(ui.shareForm, "Description", "")
+	setFormText(ui.shareForm, "Expires in days", "")
+
+	ui.shareForm.SetFocus(0)
+	ui.pages.ShowPage("share")
+	ui.app.SetFocus(ui.shareForm)
+}
+
+func (ui *Ui) hideShareForm() {
+	ui.pages.HidePage("share")
+	if ui.shareReturn != nil {
+		ui.app.SetFocus(ui.shareReturn)
+	}
+}
+
+func (ui *Ui) handleCreateShare() {
+	description := strings.TrimSpace(getFormText(ui.shareForm, "Description"))
+
+	var expires time.Time
+	if days, err := strconv.Atoi(getFormText(ui.shareForm, "Expires in days")); err == nil && days > 0 {
+		expires = time.Now().AddDate(0, 0, days)
+	}
+
+	ui.hideShareForm()
+
+	id := ui.shareId
+	go func(connection *SubsonicConnection) {
+		response, err := connection.CreateShare([]string{id}, description, expires)
+		if err != nil {
+			connection.Logger.Printf("handleCreateShare: CreateShare %s -- %s", id, err.Error())
+			return
+		}
+		if len(response.Shares.Shares) == 0 {
+			connection.Logger.Printf("handleCreateShare: CreateShare %s -- %s", id, "no share returned")
+			return
+		}
+
+		url := response.Shares.Shares[0].Url
+		ui.app.QueueUpdateDraw(func() {
+			ui.copyToClipboard(url)
+			connection.Logger.Printf("Shared %s (copied to the clipboard)", url)
+
+			if ui.connection == connection && ui.sharesLoaded {
+				ui.refreshShares()
+			}
+		})
+	}(ui.connection)
+}
+
+func (ui *Ui) handleDeleteShare() {
+	share := ui.selectedShare()
+	if share == nil {
+		return
+	}
+
+	id, url := string(share.Id), share.Url
+	go func(connection *SubsonicConnection) {
+		if _, err := connection.DeleteShare(id); err != nil {
+			connection.Logger.Printf("handleDeleteShare: DeleteShare %s -- %s", url, err.Error())
+			return
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			if ui.connection == connection {
+				ui.refreshShares()
+			}
+		})
+	}(ui.connection)
+}
+
+// copyToClipboard asks the terminal to put text on the clipboard with an
+// OSC 52 escape sequence. tcell owns the terminal, so the sequence is sent
+// once the screen has next been drawn, like the cover art. Terminals that
+// don't support it ignore it.
+func (ui *Ui) copyToClipboard(text string) {
+	ui.clipboardText = text
+}
+
+// sendClipboard sends the text last copied to the clipboard to the terminal.
+// It's called after tview has drawn the screen.
+func (ui *Ui) sendClipboard() {
+	if ui.clipboardText == "" {
+		return
+	}
+	os.Stdout.WriteString("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(ui.clipboardText)) + "\a")
+	ui.clipboardText = ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+)
+
+// cycleStreamingProfile switches to the next streaming profile. Songs already
+// in the queue are streamed with it too, from the next song on.
+func (ui *Ui) cycleStreamingProfile() {
+	if len(ui.streamingProfiles) < 2 {
+		ui.connection.Logger.Printf("Only one streaming profile is configured")
+		return
+	}
+
+	ui.streamingProfile = (ui.streamingProfile + 1) % len(ui.streamingProfiles)
+	profile := ui.streamingProfiles[ui.streamingProfile]
+	profile.apply(ui.connection)
+
+	// the song playing keeps its stream, the rest are loaded with the new
+	// settings
+	for i := range ui.player.Queue {
+		item := &ui.player.Queue[i]
+		if i == ui.player.Current || item.IsRadio {
+			continue
+		}
+		item.Uri = ui.connection.GetPlayUrl(&SubsonicEntity{Id: item.Id})
+	}
+
+	ui.connection.Logger.Printf("Streaming with the %s profile (%s)", profile.Name, profileText(profile))
+}
+
+// profileText describes the transcoding a streaming profile asks for
+func profileText(profile StreamingProfile) string {
+	format := profile.Format
+	if format == "" {
+		format = "original format"
+	}
+	if profile.MaxBitRate == 0 {
+		return format
+	}
+	return fmt.Sprintf("%s, up to %d kbps", format, profile.MaxBitRate)
+}
+
+// streamStatus returns the streaming profile, if there is a choice of them,
+// and the codec and bitrate mpv is playing, for the status bar
+func (ui *Ui) streamStatus() string {
+	return tview.Escape(ui.streamStatusText())
+}
+
+func (ui *Ui) streamStatusText() string {
+	var status string
+	if len(ui.streamingProfiles) > 1 {
+		status += "[" + ui.streamingProfiles[ui.streamingProfile].Name + "]"
+	}
+
+	if ui.player.CurrentItem() == nil {
+		return status
+	}
+	codec, bitrate := ui.player.AudioFormat()
+	if codec == "" {
+		return status
+	}
+	if bitrate > 0 {
+		return status + fmt.Sprintf("[%s %dk]", codec, bitrate/1000)
+	}
+	return status + "[" + codec + "]"
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The freedesktop Secret Service, the keyring API of GNOME Keyring, KWallet
+// and KeePassXC
+const (
+	secretServiceName         = "org.freedesktop.secrets"
+	secretServicePath         = "/org/freedesktop/secrets"
+	secretServiceInterface    = "org.freedesktop.Secret.Service"
+	secretCollectionInterface = "org.freedesktop.Secret.Collection"
+	secretItemInterface       = "org.freedesktop.Secret.Item"
+	secretSessionInterface    = "org.freedesktop.Secret.Session"
+	secretPromptInterface     = "org.freedesktop.Secret.Prompt"
+)
+
+// the path the service returns in place of an object that doesn't exist, such
+// as a prompt when it doesn't need to ask the user anything
+const noObject = dbus.ObjectPath("/")
+
+var ErrNoKeyringPassword = errors.New("no password stored in the keyring")
+
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type secretService struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	session dbus.ObjectPath
+}
+
+func openKeyring() (*secretService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &secretService{
+		conn:    conn,
+		service: conn.Object(secretServiceName, secretServicePath),
+	}
+	// secrets are only sent over the session bus, so they needn't be
+	// encrypted on top of that
+	var output dbus.Variant
+	err = keyring.service.Call(secretServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &keyring.session)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return keyring, nil
+}
+
+func (keyring *secretService) Close() {
+	keyring.conn.Object(secretServiceName, keyring.session).Call(secretSessionInterface+".Close", 0)
+	keyring.conn.Close()
+}
+
+// passwordAttributes identify the password of a user on a server among
+// everything else in the keyring
+func passwordAttributes(host string, username string) map[string]string {
+	return map[string]string{
+		"application": "stmp",
+		"server":      host,
+		"username":    username,
+	}
+}
+
+// prompt asks the user to unlock the keyring, if the service needs it to,
+// and waits until they have
+func (keyring *secretService) prompt(path dbus.ObjectPath) error {
+	if path == noObject {
+		return nil
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretPromptInterface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := keyring.conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer keyring.conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	keyring.conn.Signal(signals)
+	defer keyring.conn.RemoveSignal(signals)
+
+	err := keyring.conn.Object(secretServiceName, path).Call(secretPromptInterface+".Prompt", 0, "").Err
+	if err != nil {
+		return err
+	}
+
+	for signal := range signals {
+		if signal.Path != path || signal.Name != secretPromptInterface+".Completed" || len(signal.Body) != 2 {
+			continue
+		}
+		if dismissed, _ := signal.Body[0].(bool); dismissed {
+			return fmt.Errorf("unlocking the keyring was cancelled")
+		}
+		return nil
+	}
+	return fmt.Errorf("lost connection to the keyring")
+}
+
+func (keyring *secretService) unlock(path dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := keyring.service.Call(secretServiceInterface+".Unlock", 0, []dbus.ObjectPath{path}).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+	return keyring.prompt(prompt)
+}
+
+// KeyringPassword returns the password stored for a user on a server with
+// StoreKeyringPassword, or ErrNoKeyringPassword if there isn't one
+func KeyringPassword(host string, username string) (string, error) {
+	keyring, err := openKeyring()
+	if err != nil {
+		return "", err
+	}
+	defer keyring.Close()
+
+	var unlocked, locked []dbus.ObjectPath
+	err = keyring.service.Call(secretServiceInterface+".SearchItems", 0, passwordAttributes(host, username)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", err
+	}
+
+	var item dbus.ObjectPath
+	if len(unlocked) > 0 {
+		item = unlocked[0]
+	} else if len(locked) > 0 {
+		item = locked[0]
+		if err := keyring.unlock(item); err != nil {
+			return "", err
+		}
+	} else {
+		return "", ErrNoKeyringPassword
+	}
+
+	var password secret
+	err = keyring.conn.Object(secretServiceName, item).Call(secretItemInterface+".GetSecret", 0, keyring.session).
+		Store(&password)
+	if err != nil {
+		return "", err
+	}
+	return string(password.Value), nil
+}
+
+// StoreKeyringPassword stores the password of a user on a server in the
+// default keyring, replacing any stored before
+func StoreKeyringPassword(host string, username string, password string) error {
+	keyring, err := openKeyring()
+	if err != nil {
+		return err
+	}
+	defer keyring.Close()
+
+	var collection dbus.ObjectPath
+	err = keyring.service.Call(secretServiceInterface+".ReadAlias", 0, "default").Store(&collection)
+	if err != nil {
+		return err
+	}
+	if collection == noObject {
+		return fmt.Errorf("there is no default keyring")
+	}
+	if err := keyring.unlock(collection); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		secretItemInterface + ".Label":      dbus.MakeVariant(fmt.Sprintf("stmp password for %s on %s", username, host)),
+		secretItemInterface + ".Attributes": dbus.MakeVariant(passwordAttributes(host, username)),
+	}
+	value := secret{
+		Session:     keyring.session,
+		Value:       []byte(password),
+		ContentType: "text/plain",
+	}
+
+	var item, prompt dbus.ObjectPath
+	err = keyring.conn.Object(secretServiceName, collection).Call(secretCollectionInterface+".CreateItem", 0, properties, value, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	return keyring.prompt(prompt)
+}
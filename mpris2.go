@@ -11,6 +11,7 @@ import (
 
 type MprisPlayer struct {
 	conn   *dbus.Conn
+	ui     *Ui
 	player *Player
 	logger Logger
 }
@@ -22,7 +23,8 @@ func (mpp MprisPlayer) Stop() {
 	}
 }
 func (mpp MprisPlayer) Next() {
-	mpp.player.PlayNextTrack()
+	// the queue belongs to the UI goroutine, which shows the change
+	mpp.ui.app.QueueUpdateDraw(mpp.ui.handleNextTrack)
 }
 func (mpp MprisPlayer) Pause() {
 	psd, err := mpp.player.IsPaused()
@@ -55,7 +57,7 @@ func (mpp MprisPlayer) OpenUri(string) {
 	// TODO not implemented
 }
 func (mpp MprisPlayer) Previous() {
-	// TODO not implemented
+	mpp.ui.app.QueueUpdateDraw(mpp.ui.handlePreviousTrack)
 }
 func (mpp MprisPlayer) Seek(int) {
 	// TODO not implemented
@@ -67,7 +69,7 @@ func (mpp MprisPlayer) SetPosition(string, int) {
 	// TODO not implemented
 }
 
-func RegisterPlayer(p *Player, l Logger) (MprisPlayer, error) {
+func RegisterPlayer(ui *Ui, l Logger) (MprisPlayer, error) {
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		return MprisPlayer{}, err
@@ -76,7 +78,8 @@ func RegisterPlayer(p *Player, l Logger) (MprisPlayer, error) {
 	name := strings.Join(parts[1:], ".")
 	mpp := MprisPlayer{
 		conn:   conn,
-		player: p,
+		ui:     ui,
+		player: ui.player,
 		logger: l,
 	}
 	err = conn.ExportAll(mpp, "/org/mpris/MediaPlayer2", "org.mpris.MediaPlayer2.Player")
@@ -85,10 +88,10 @@ func RegisterPlayer(p *Player, l Logger) (MprisPlayer, error) {
 	}
 	/*
 		func (mpp MprisPlayer) Metadata() string {
-			if len(mpp.player.Queue) == 0 {
+			playing := mpp.player.CurrentItem()
+			if playing == nil {
 				return ""
 			}
-			playing := mpp.player.Queue[0]
 			return fmt.Sprintf("%s - %s", playing.Artist, playing.Title)
 		}
 		Shuffle true/false
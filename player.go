@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/wildeyedskies/go-mpv/mpv"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -17,14 +18,33 @@ type QueueItem struct {
 	Uri      string
 	Title    string
 	Artist   string
+	Album    string
 	Duration int
+	// id of the cover art shown while the item plays
+	CoverArt string
+	// ratings of songs from the server, shown in the queue
+	UserRating    int
+	AverageRating float64
+	// internet radio streams have no duration, and aren't scrobbled
+	IsRadio bool
+	// set for podcast episodes, which resume where they were left off
+	EpisodeId string
+	// kept for offline use, rather than only downloaded ahead of playing it
+	Pinned bool
 }
 
 type Player struct {
-	Instance          *mpv.Mpv
-	EventChannel      chan *mpv.Event
-	Queue             []QueueItem
+	Instance     *mpv.Mpv
+	EventChannel chan *mpv.Event
+	// the queue keeps the items already played, before Current
+	Queue []QueueItem
+	// index of the item playing, or to be played once playback starts.
+	// len(Queue) once everything has been played.
+	Current           int
 	ReplaceInProgress bool
+	// position to seek to once the next file has loaded, used to resume a
+	// track part way through
+	ResumePosition float64
 }
 
 func eventListener(m *mpv.Mpv) chan *mpv.Event {
@@ -38,36 +58,206 @@ func eventListener(m *mpv.Mpv) chan *mpv.Event {
 	return c
 }
 
-func InitPlayer() (*Player, error) {
+func InitPlayer(config ClientConfig) (*Player, error) {
 	mpvInstance := mpv.Create()
 
 	// TODO figure out what other mpv options we need
 	mpvInstance.SetOptionString("audio-display", "no")
 	mpvInstance.SetOptionString("video", "no")
 
+	configureStreaming(mpvInstance, config)
+
 	err := mpvInstance.Initialize()
 	if err != nil {
 		mpvInstance.TerminateDestroy()
 		return nil, err
 	}
 
-	return &Player{mpvInstance, eventListener(mpvInstance), make([]QueueItem, 0), false}, nil
+	return &Player{mpvInstance, eventListener(mpvInstance), make([]QueueItem, 0), 0, false, 0}, nil
 }
 
-func (p *Player) PlayNextTrack() error {
-	if len(p.Queue) > 0 {
-		return p.Instance.Command([]string{"loadfile", p.Queue[0].Uri})
+// configureStreaming makes mpv stream with the same settings the API client
+// connects with
+func configureStreaming(mpvInstance *mpv.Mpv, config ClientConfig) {
+	if config.InsecureSkipVerify {
+		mpvInstance.SetOptionString("tls-verify", "no")
+	} else {
+		mpvInstance.SetOptionString("tls-verify", "yes")
+	}
+	// every option is set, so none are left over from a previous server
+	mpvInstance.SetOptionString("tls-ca-file", config.CAFile)
+	keyFile := config.KeyFile
+	if keyFile == "" {
+		keyFile = config.CertFile
+	}
+	mpvInstance.SetOptionString("tls-cert-file", config.CertFile)
+	mpvInstance.SetOptionString("tls-key-file", keyFile)
+	// mpv only supports http proxies
+	if strings.HasPrefix(config.Proxy, "http://") {
+		mpvInstance.SetOptionString("http-proxy", config.Proxy)
+	} else {
+		mpvInstance.SetOptionString("http-proxy", "")
+	}
+	mpvInstance.SetOptionString("network-timeout", strconv.Itoa(int(config.Timeout.Seconds())))
+}
+
+// Configure changes the settings mpv streams with, for songs loaded from now on
+func (p *Player) Configure(config ClientConfig) {
+	configureStreaming(p.Instance, config)
+}
+
+// how far into a track Previous starts it over, rather than going back to the
+// one before it
+const previousRestartPosition = 3.0
+
+// CurrentItem returns the item playing, or nil if the whole queue has been
+// played
+func (p *Player) CurrentItem() *QueueItem {
+	if p.Current < 0 || p.Current >= len(p.Queue) {
+		return nil
+	}
+	return &p.Queue[p.Current]
+}
+
+// Remaining returns the number of items left to play, counting the current one
+func (p *Player) Remaining() int {
+	if p.Current >= len(p.Queue) {
+		return 0
+	}
+	return len(p.Queue) - p.Current
+}
+
+// PlayCurrent loads the current item, after the one before it finished
+func (p *Player) PlayCurrent() error {
+	if item := p.CurrentItem(); item != nil {
+		// only the item a position was set for resumes from it
+		p.ResumePosition = 0
+		return p.Instance.Command([]string{"loadfile", item.Uri})
 	}
 	return nil
 }
 
-func (p *Player) Play(id string, uri string, title string, artist string, duration int) error {
-	p.Queue = []QueueItem{{id, uri, title, artist, duration}}
+// Play jumps to an item of the queue and plays it, keeping the rest of the
+// queue as it is
+func (p *Player) Play(index int) error {
+	if index < 0 || index >= len(p.Queue) {
+		return nil
+	}
+
+	p.Current = index
 	p.ReplaceInProgress = true
+	p.ResumePosition = 0
 	if ip, e := p.IsPaused(); ip && e == nil {
 		p.Pause()
 	}
-	return p.Instance.Command([]string{"loadfile", uri})
+	return p.Instance.Command([]string{"loadfile", p.Queue[index].Uri})
+}
+
+// Next skips to the item after the current one, or stops if it's the last
+func (p *Player) Next() error {
+	if p.Current+1 < len(p.Queue) {
+		return p.Play(p.Current + 1)
+	}
+	if p.Current >= len(p.Queue) {
+		return nil
+	}
+	p.Current = len(p.Queue)
+	return p.Stop()
+}
+
+// Previous goes back to the item before the current one, or starts the
+// current one over if it has been playing for a few seconds
+func (p *Player) Previous() error {
+	if p.CurrentItem() != nil {
+		if loaded, err := p.IsSongLoaded(); err == nil && loaded {
+			if position, err := p.Position(); err == nil && position > previousRestartPosition {
+				return p.SeekTo(0)
+			}
+		}
+	}
+	if p.Current == 0 {
+		return nil
+	}
+	return p.Play(p.Current - 1)
+}
+
+// Remove takes an item out of the queue. Removing the current item plays the
+// one after it, if it was playing.
+func (p *Player) Remove(index int) error {
+	if index < 0 || index >= len(p.Queue) {
+		return nil
+	}
+
+	p.Queue = append(p.Queue[:index], p.Queue[index+1:]...)
+	if index < p.Current {
+		p.Current--
+		return nil
+	} else if index > p.Current {
+		return nil
+	}
+
+	loaded, err := p.IsSongLoaded()
+	if err != nil || !loaded {
+		return err
+	}
+	if p.Current < len(p.Queue) {
+		return p.Play(p.Current)
+	}
+	return p.Stop()
+}
+
+// Clear empties the queue and stops playing
+func (p *Player) Clear() error {
+	p.Queue = make([]QueueItem, 0)
+	p.Current = 0
+	return p.Stop()
+}
+
+// Replace swaps the queue for the given items and starts playing the first one
+func (p *Player) Replace(items []QueueItem) error {
+	return p.ReplaceFrom(items, 0)
+}
+
+// ReplaceFrom is like Replace, but starts playing position seconds into the
+// first item
+func (p *Player) ReplaceFrom(items []QueueItem, position float64) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	p.Queue = items
+	p.Current = 0
+	p.ReplaceInProgress = true
+	p.ResumePosition = position
+	if ip, e := p.IsPaused(); ip && e == nil {
+		p.Pause()
+	}
+	return p.Instance.Command([]string{"loadfile", items[0].Uri})
+}
+
+// StreamTitle returns the title of the song an internet radio stream says it
+// is playing, from its ICY metadata
+func (p *Player) StreamTitle() string {
+	title, err := p.Instance.GetProperty("metadata/by-key/icy-title", mpv.FORMAT_STRING)
+	if err != nil || title == nil {
+		return ""
+	}
+	return title.(string)
+}
+
+// AudioFormat returns the codec of the track being played and its bitrate in
+// bits per second, as mpv decodes it. The bitrate is 0 until mpv knows it.
+func (p *Player) AudioFormat() (string, int64) {
+	var codec string
+	if name, err := p.Instance.GetProperty("audio-codec-name", mpv.FORMAT_STRING); err == nil && name != nil {
+		codec = name.(string)
+	}
+
+	var bitrate int64
+	if rate, err := p.Instance.GetProperty("audio-bitrate", mpv.FORMAT_INT64); err == nil && rate != nil {
+		bitrate = rate.(int64)
+	}
+	return codec, bitrate
 }
 
 func (p *Player) Stop() error {
@@ -76,12 +266,18 @@ func (p *Player) Stop() error {
 
 func (p *Player) IsSongLoaded() (bool, error) {
 	idle, err := p.Instance.GetProperty("idle-active", mpv.FORMAT_FLAG)
-	return !idle.(bool), err
+	if err != nil || idle == nil {
+		return false, err
+	}
+	return !idle.(bool), nil
 }
 
 func (p *Player) IsPaused() (bool, error) {
 	pause, err := p.Instance.GetProperty("pause", mpv.FORMAT_FLAG)
-	return pause.(bool), err
+	if err != nil || pause == nil {
+		return false, err
+	}
+	return pause.(bool), nil
 }
 
 // Pause toggles playing music
@@ -107,8 +303,8 @@ func (p *Player) Pause() (int, error) {
 		}
 		return PlayerPaused, nil
 	} else {
-		if len(p.Queue) != 0 {
-			err := p.Instance.Command([]string{"loadfile", p.Queue[0].Uri})
+		if item := p.CurrentItem(); item != nil {
+			err := p.Instance.Command([]string{"loadfile", item.Uri})
 			return PlayerPlaying, err
 		} else {
 			return PlayerStopped, nil
@@ -148,3 +344,20 @@ func (p *Player) Volume() (int64, error) {
 func (p *Player) Seek(increment int) error {
 	return p.Instance.Command([]string{"seek", strconv.Itoa(increment)})
 }
+
+// SeekTo seeks to a position, in seconds, from the start of the track
+func (p *Player) SeekTo(position float64) error {
+	return p.Instance.Command([]string{"seek", strconv.FormatFloat(position, 'f', 3, 64), "absolute"})
+}
+
+// Position returns how far into the current track playback is, in seconds
+func (p *Player) Position() (float64, error) {
+	position, err := p.Instance.GetProperty("time-pos", mpv.FORMAT_DOUBLE)
+	if err != nil {
+		return 0, err
+	}
+	if position == nil {
+		return 0, nil
+	}
+	return position.(float64), nil
+}
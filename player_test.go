@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wildeyedskies/go-mpv/mpv"
+)
+
+// newTestPlayer returns a player with a queue of songs a, b and c, at
+// current. mpv isn't initialized, so nothing is ever played.
+func newTestPlayer(current int) *Player {
+	queue := []QueueItem{{Id: "a"}, {Id: "b"}, {Id: "c"}}
+	return &Player{Instance: mpv.Create(), Queue: queue, Current: current}
+}
+
+func queueIds(p *Player) []string {
+	ids := make([]string, 0, len(p.Queue))
+	for _, item := range p.Queue {
+		ids = append(ids, item.Id)
+	}
+	return ids
+}
+
+func TestPlayerIndexes(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int
+		action      func(p *Player) error
+		wantCurrent int
+		wantIds     []string
+	}{
+		{"play", 0, func(p *Player) error { return p.Play(2) }, 2, []string{"a", "b", "c"}},
+		{"play before the queue", 1, func(p *Player) error { return p.Play(-1) }, 1, []string{"a", "b", "c"}},
+		{"play past the queue", 1, func(p *Player) error { return p.Play(3) }, 1, []string{"a", "b", "c"}},
+		{"next", 0, (*Player).Next, 1, []string{"a", "b", "c"}},
+		{"next from the last", 2, (*Player).Next, 3, []string{"a", "b", "c"}},
+		{"next once all played", 3, (*Player).Next, 3, []string{"a", "b", "c"}},
+		{"previous", 2, (*Player).Previous, 1, []string{"a", "b", "c"}},
+		{"previous from the first", 0, (*Player).Previous, 0, []string{"a", "b", "c"}},
+		{"previous once all played", 3, (*Player).Previous, 2, []string{"a", "b", "c"}},
+		{"remove before current", 2, func(p *Player) error { return p.Remove(0) }, 1, []string{"b", "c"}},
+		{"remove current", 1, func(p *Player) error { return p.Remove(1) }, 1, []string{"a", "c"}},
+		{"remove current last", 2, func(p *Player) error { return p.Remove(2) }, 2, []string{"a", "b"}},
+		{"remove after current", 0, func(p *Player) error { return p.Remove(1) }, 0, []string{"a", "c"}},
+		{"remove once all played", 3, func(p *Player) error { return p.Remove(0) }, 2, []string{"b", "c"}},
+		{"remove past the queue", 1, func(p *Player) error { return p.Remove(3) }, 1, []string{"a", "b", "c"}},
+	}
+
+	for _, test := range tests {
+		p := newTestPlayer(test.current)
+		// errors from mpv, which isn't running, don't matter here
+		test.action(p)
+		if p.Current != test.wantCurrent {
+			t.Errorf("%s: current is %d, want %d", test.name, p.Current, test.wantCurrent)
+		}
+		if ids := queueIds(p); !reflect.DeepEqual(ids, test.wantIds) {
+			t.Errorf("%s: queue is %v, want %v", test.name, ids, test.wantIds)
+		}
+	}
+}
+
+func TestPlayerPlayForgetsResumePosition(t *testing.T) {
+	p := newTestPlayer(0)
+	p.ResumePosition = 42
+	p.Play(1)
+	if p.ResumePosition != 0 {
+		t.Errorf("resume position is %f after playing another song, want 0", p.ResumePosition)
+	}
+
+	p.ResumePosition = 42
+	p.Current = 2
+	p.PlayCurrent()
+	if p.ResumePosition != 0 {
+		t.Errorf("resume position is %f after moving on to the next song, want 0", p.ResumePosition)
+	}
+}
@@ -1,26 +1,56 @@
 package main
 
 import (
+	"bufio"
+	"crypto/md5"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 func readConfig() {
-	required_properties := []string{"auth.username", "auth.password", "server.host"}
-
 	viper.SetConfigName("stmp")
 	viper.SetConfigType("toml")
 	viper.AddConfigPath("$HOME/.config/stmp")
 	viper.AddConfigPath(".")
 
+	viper.SetDefault("server.syncPlayQueue", true)
+	viper.SetDefault("server.timeout", 30)
+	viper.SetDefault("server.retries", 2)
+
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.directoryTtl", "24h")
+	viper.SetDefault("cache.playlistTtl", "1h")
+
+	viper.SetDefault("downloads.enabled", true)
+	viper.SetDefault("downloads.maxSize", 2048)
+	viper.SetDefault("downloads.transcode", false)
+
+	viper.SetDefault("prefetch.enabled", true)
+	viper.SetDefault("prefetch.tracks", 1)
+
+	viper.SetDefault("ui.coverArt", GraphicsAuto)
+	viper.SetDefault("ui.coverArtCacheSize", 100)
+
+	viper.SetDefault("artistRadio.topUp", true)
+
+	// Random songs
+	viper.SetDefault("random.size", 50)
+
 	// Keybinds
 	viper.SetDefault("keys.search", "/")
 	viper.SetDefault("keys.searchNext", "n")
 	viper.SetDefault("keys.searchPrev", "N")
 	viper.SetDefault("keys.refresh", "r")
+	viper.SetDefault("keys.toggleBrowseMode", "b")
 	viper.SetDefault("keys.add", "a")
 	viper.SetDefault("keys.star", "y")
 	viper.SetDefault("keys.newPlaylist", "a")
@@ -31,14 +61,44 @@ func readConfig() {
 	viper.SetDefault("keys.pageQueue", "2")
 	viper.SetDefault("keys.pagePlaylists", "3")
 	viper.SetDefault("keys.pageLog", "4")
+	viper.SetDefault("keys.pageSearch", "5")
+	viper.SetDefault("keys.pageAlbums", "6")
+	viper.SetDefault("keys.cycleAlbumList", "c")
+	viper.SetDefault("keys.pageGenres", "7")
+	viper.SetDefault("keys.pageRadio", "8")
+	viper.SetDefault("keys.newRadioStation", "n")
+	viper.SetDefault("keys.editRadioStation", "e")
+	viper.SetDefault("keys.deleteRadioStation", "d")
+	viper.SetDefault("keys.pagePodcasts", "9")
+	viper.SetDefault("keys.newPodcastChannel", "n")
+	viper.SetDefault("keys.deletePodcastChannel", "d")
+	viper.SetDefault("keys.downloadEpisode", "g")
+	viper.SetDefault("keys.pageBookmarks", "0")
+	viper.SetDefault("keys.bookmark", "B")
+	viper.SetDefault("keys.pageLyrics", "l")
+	viper.SetDefault("keys.pageShares", "U")
+	viper.SetDefault("keys.share", "u")
+	viper.SetDefault("keys.startArtistRadio", "i")
+	viper.SetDefault("keys.musicFolder", "f")
+	viper.SetDefault("keys.switchServer", "P")
+	viper.SetDefault("keys.cycleStreamingProfile", "t")
+	viper.SetDefault("keys.pinOffline", "o")
+	viper.SetDefault("keys.deleteShare", "d")
+	viper.SetDefault("keys.deleteBookmark", "d")
+	viper.SetDefault("keys.rate", "R")
+	viper.SetDefault("keys.nextPage", "]")
+	viper.SetDefault("keys.prevPage", "[")
 	viper.SetDefault("keys.quit", "q")
 	viper.SetDefault("keys.addRandomSongs", "s")
+	viper.SetDefault("keys.addRandomSongsFiltered", "S")
 	viper.SetDefault("keys.clearQueue", "D")
 	viper.SetDefault("keys.playPause", "p")
 	viper.SetDefault("keys.volumeDown", "-")
 	viper.SetDefault("keys.volumeUp", "=")
 	viper.SetDefault("keys.seekForward", ".")
 	viper.SetDefault("keys.seekBack", ",")
+	viper.SetDefault("keys.nextTrack", ">")
+	viper.SetDefault("keys.previousTrack", "<")
 	viper.SetDefault("keys.up", "Up")
 	viper.SetDefault("keys.down", "Down")
 	viper.SetDefault("keys.left", "Left")
@@ -50,12 +110,27 @@ func readConfig() {
 		fmt.Printf("Config file error: %s \n", err)
 		os.Exit(1)
 	}
+}
+
+// cacheDir returns the directory stmp keeps its state in, creating it if it
+// doesn't exist yet
+func cacheDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
 
-	for _, prop := range required_properties {
-		if !viper.IsSet(prop) {
-			fmt.Printf("Config property %s is required\n", prop)
-		}
+	dir := filepath.Join(userCacheDir, "stmp")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
 	}
+	return dir, nil
+}
+
+// serverKey names the files and directories kept for a user of a server, so
+// they don't mix with those of other servers and users
+func serverKey(host string, username string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(host+"\n"+username)))
 }
 
 type Logger struct {
@@ -66,9 +141,333 @@ func (l Logger) Printf(s string, as ...interface{}) {
 	l.prints <- fmt.Sprintf(s, as...)
 }
 
+// selectMusicFolder limits the connection to the music folder with the given
+// name or id
+func selectMusicFolder(connection *SubsonicConnection, folder string) error {
+	response, err := connection.GetMusicFolders()
+	if err != nil {
+		return err
+	}
+
+	for _, musicFolder := range response.MusicFolders.Folders {
+		if musicFolder.Name == folder || string(musicFolder.Id) == folder {
+			connection.MusicFolderId = string(musicFolder.Id)
+			return nil
+		}
+	}
+	return fmt.Errorf("no such music folder")
+}
+
+// the server configured in [server] and [auth], rather than in [servers]
+const defaultServerProfile = "default"
+
+// serverProfileNames returns the names of the configured servers, starting
+// with the default one if there is one
+func serverProfileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("servers") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if viper.IsSet("server.host") {
+		names = append([]string{defaultServerProfile}, names...)
+	}
+	return names
+}
+
+// serverConfig returns the settings of a server profile under the keys of
+// [servers.<name>]. Connection settings from [server] apply to every server
+// that doesn't set its own, but credentials are never shared.
+func serverConfig(profile string) (*viper.Viper, error) {
+	config := viper.New()
+	for _, key := range []string{"scrobble", "timeout", "retries", "caFile", "insecureSkipVerify", "certFile", "keyFile", "proxy"} {
+		config.SetDefault(key, viper.Get("server."+key))
+	}
+
+	if profile == defaultServerProfile {
+		for _, key := range []string{"host", "musicFolder"} {
+			if viper.IsSet("server." + key) {
+				config.Set(key, viper.Get("server."+key))
+			}
+		}
+		for _, key := range []string{"username", "password", "password_command", "password_env", "plaintext", "apiKey"} {
+			if viper.IsSet("auth." + key) {
+				config.Set(key, viper.Get("auth."+key))
+			}
+		}
+	} else {
+		if !viper.IsSet("servers." + profile) {
+			return nil, fmt.Errorf("no such server profile")
+		}
+		for key, value := range viper.GetStringMap("servers." + profile) {
+			config.Set(key, value)
+		}
+	}
+
+	// an API key stands in for the username and password, which may come
+	// from elsewhere than the config, see serverPassword
+	required := []string{"host", "username"}
+	if config.GetString("apiKey") != "" {
+		required = []string{"host"}
+	}
+	for _, key := range required {
+		if !config.IsSet(key) {
+			return nil, fmt.Errorf("%s is required", key)
+		}
+	}
+	return config, nil
+}
+
+// serverPassword returns the password of a server profile, from the first of
+// password, password_command, password_env and the keyring it's set in
+func serverPassword(config *viper.Viper) (string, error) {
+	if config.IsSet("password") {
+		return config.GetString("password"), nil
+	}
+
+	if command := config.GetString("password_command"); command != "" {
+		output, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				return "", fmt.Errorf("password_command: %s", strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return "", fmt.Errorf("password_command: %w", err)
+		}
+		// password managers such as pass may print more after the first line
+		password := strings.SplitN(string(output), "\n", 2)[0]
+		return strings.TrimSuffix(password, "\r"), nil
+	}
+
+	if name := config.GetString("password_env"); name != "" {
+		password, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("password_env: %s isn't set", name)
+		}
+		return password, nil
+	}
+
+	password, err := KeyringPassword(config.GetString("host"), config.GetString("username"))
+	if err == ErrNoKeyringPassword {
+		return "", fmt.Errorf("no password configured, and none stored in the keyring with --store-password")
+	} else if err != nil {
+		return "", fmt.Errorf("reading the password from the keyring: %w", err)
+	}
+	return password, nil
+}
+
+// storePassword asks for the password of a server profile, and stores it in
+// the keyring to be used in place of one in the config
+func storePassword(profile string) error {
+	config, err := serverConfig(profile)
+	if err != nil {
+		return err
+	}
+	host := config.GetString("host")
+	username := config.GetString("username")
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+
+	password, err := readPassword(fmt.Sprintf("Password for %s on %s: ", username, host))
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return fmt.Errorf("no password entered")
+	}
+	return StoreKeyringPassword(host, username, password)
+}
+
+// readPassword reads a line from stdin, without echoing it if stdin is a
+// terminal
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	stty := exec.Command("stty", "-echo")
+	stty.Stdin = os.Stdin
+	if err := stty.Run(); err == nil {
+		defer func() {
+			restore := exec.Command("stty", "echo")
+			restore.Stdin = os.Stdin
+			restore.Run()
+			// the newline typed wasn't echoed either
+			fmt.Println()
+		}()
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// connectToServer sets up a connection to the server of a profile, with its
+// caches, extensions and music folder
+func connectToServer(profile string, logger Logger) (*SubsonicConnection, ClientConfig, error) {
+	config, err := serverConfig(profile)
+	if err != nil {
+		return nil, ClientConfig{}, err
+	}
+
+	clientConfig := ClientConfig{
+		Timeout:            time.Duration(config.GetInt("timeout")) * time.Second,
+		Retries:            config.GetInt("retries"),
+		CAFile:             config.GetString("caFile"),
+		InsecureSkipVerify: config.GetBool("insecureSkipVerify"),
+		CertFile:           config.GetString("certFile"),
+		KeyFile:            config.GetString("keyFile"),
+		Proxy:              config.GetString("proxy"),
+	}
+	client, err := NewHTTPClient(clientConfig)
+	if err != nil {
+		return nil, clientConfig, err
+	}
+	downloadClient, err := NewDownloadClient(clientConfig)
+	if err != nil {
+		return nil, clientConfig, err
+	}
+	if clientConfig.Proxy != "" && !strings.HasPrefix(clientConfig.Proxy, "http://") {
+		logger.Printf("mpv only supports http proxies, so streams don't go through %s", clientConfig.Proxy)
+	}
+
+	var password string
+	if config.GetString("apiKey") == "" {
+		password, err = serverPassword(config)
+		if err != nil {
+			return nil, clientConfig, err
+		}
+	}
+
+	connection := &SubsonicConnection{
+		ProfileName:    profile,
+		Username:       config.GetString("username"),
+		Password:       password,
+		Host:           config.GetString("host"),
+		PlaintextAuth:  config.GetBool("plaintext"),
+		ApiKey:         config.GetString("apiKey"),
+		Scrobble:       config.GetBool("scrobble"),
+		Client:         client,
+		DownloadClient: downloadClient,
+		Retries:        clientConfig.Retries,
+		Logger:         logger,
+		directoryCache: make(map[string]SubsonicResponse),
+	}
+
+	if viper.GetBool("cache.enabled") {
+		cache, err := NewMetadataCache(connection.Host, connection.Username+connection.ApiKey)
+		if err != nil {
+			logger.Printf("NewMetadataCache -- %s", err.Error())
+		} else {
+			cache.DirectoryTTL = viper.GetDuration("cache.directoryTtl")
+			cache.PlaylistTTL = viper.GetDuration("cache.playlistTtl")
+			connection.Cache = cache
+		}
+	}
+
+	// prefetched songs are kept in the track cache too, so it's needed for
+	// either
+	if viper.GetBool("downloads.enabled") || viper.GetBool("prefetch.enabled") {
+		tracks, err := NewTrackCache(connection.Host, connection.Username+connection.ApiKey)
+		if err != nil {
+			logger.Printf("NewTrackCache -- %s", err.Error())
+		} else {
+			// configured in megabytes
+			tracks.MaxSize = int64(viper.GetInt("downloads.maxSize")) << 20
+			connection.Tracks = tracks
+		}
+	}
+
+	profileName := viper.GetString("streaming.profile")
+	profileIndex, err := streamingProfileIndex(streamingProfiles(), profileName)
+	if err != nil {
+		return nil, clientConfig, fmt.Errorf("selecting streaming profile %s: %w", profileName, err)
+	}
+	streamingProfiles()[profileIndex].apply(connection)
+
+	// servers that aren't OpenSubsonic may not answer this at all, so carry
+	// on without any extensions
+	if err := connection.DetectExtensions(); err != nil {
+		logger.Printf("DetectExtensions -- %s", err.Error())
+	}
+	if connection.ApiKey != "" && !connection.HasExtension(ExtensionApiKey) {
+		return nil, clientConfig, fmt.Errorf("the server doesn't support API key authentication")
+	}
+
+	if folder := config.GetString("musicFolder"); folder != "" {
+		if err := selectMusicFolder(connection, folder); err != nil {
+			return nil, clientConfig, fmt.Errorf("selecting music folder %s: %w", folder, err)
+		}
+	}
+
+	return connection, clientConfig, nil
+}
+
+// StreamingProfile is a set of transcoding settings streams can be switched
+// between, such as one for home and one for mobile data
+type StreamingProfile struct {
+	Name string
+	// in kbps, 0 for no limit
+	MaxBitRate int
+	// format to transcode to, "raw" or empty for the original
+	Format string
+}
+
+// streamingProfiles returns the profiles configured in [streaming.profiles],
+// sorted by name, or a single profile from [streaming] itself if there are
+// none
+func streamingProfiles() []StreamingProfile {
+	configured := viper.GetStringMap("streaming.profiles")
+	if len(configured) == 0 {
+		return []StreamingProfile{{
+			Name:       "default",
+			MaxBitRate: viper.GetInt("streaming.maxBitRate"),
+			Format:     viper.GetString("streaming.format"),
+		}}
+	}
+
+	names := make([]string, 0, len(configured))
+	for name := range configured {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make([]StreamingProfile, 0, len(names))
+	for _, name := range names {
+		profiles = append(profiles, StreamingProfile{
+			Name:       name,
+			MaxBitRate: viper.GetInt("streaming.profiles." + name + ".maxBitRate"),
+			Format:     viper.GetString("streaming.profiles." + name + ".format"),
+		})
+	}
+	return profiles
+}
+
+// streamingProfileIndex returns the index of the profile with the given name,
+// or the first profile if name is empty
+func streamingProfileIndex(profiles []StreamingProfile, name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for i, profile := range profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no such streaming profile")
+}
+
+func (profile StreamingProfile) apply(connection *SubsonicConnection) {
+	connection.MaxBitRate = profile.MaxBitRate
+	connection.Format = profile.Format
+}
+
 func main() {
 	help := flag.Bool("help", false, "Print usage")
 	enableMpris := flag.Bool("mpris", false, "Enable MPRIS2")
+	profile := flag.String("profile", "", "Name of the server to connect to, from [servers.<name>] or 'default' for [server]")
+	storePasswordFlag := flag.Bool("store-password", false, "Store the password of the server in the system keyring, then exit")
 	flag.Parse()
 	if *help {
 		fmt.Printf("USAGE: %s <args>\n", os.Args[0])
@@ -80,14 +479,30 @@ func main() {
 
 	logger := Logger{make(chan string, 100)}
 
-	connection := &SubsonicConnection{
-		Username:       viper.GetString("auth.username"),
-		Password:       viper.GetString("auth.password"),
-		Host:           viper.GetString("server.host"),
-		PlaintextAuth:  viper.GetBool("auth.plaintext"),
-		Scrobble:       viper.GetBool("server.scrobble"),
-		Logger:         logger,
-		directoryCache: make(map[string]SubsonicResponse),
+	profiles := serverProfileNames()
+	if len(profiles) == 0 {
+		fmt.Println("No server configured, in [server] or [servers.<name>]")
+		os.Exit(1)
+	}
+	if *profile == "" {
+		*profile = profiles[0]
+	}
+	// config keys aren't case sensitive, so profile names aren't either
+	*profile = strings.ToLower(*profile)
+
+	if *storePasswordFlag {
+		if err := storePassword(*profile); err != nil {
+			fmt.Printf("Error storing the password of server %s: %s\n", *profile, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Password of server %s stored in the keyring\n", *profile)
+		os.Exit(0)
+	}
+
+	connection, clientConfig, err := connectToServer(*profile, logger)
+	if err != nil {
+		fmt.Printf("Error connecting to server %s: %s\n", *profile, err)
+		os.Exit(1)
 	}
 
 	indexResponse, err := connection.GetIndexes()
@@ -101,14 +516,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	player, err := InitPlayer()
+	player, err := InitPlayer(clientConfig)
 	if err != nil {
 		fmt.Println("Unable to initialize mpv. Is mpv installed?")
 		os.Exit(1)
 	}
 
+	ui := InitGui(&indexResponse.Indexes.Index, &playlistResponse.Playlists.Playlists, connection, player)
+
 	if *enableMpris {
-		mpris, err := RegisterPlayer(player, logger)
+		mpris, err := RegisterPlayer(ui, logger)
 		if err != nil {
 			fmt.Printf("Unable to register MPRIS with DBUS: %s\n", err)
 			fmt.Println("Try running without MPRIS")
@@ -117,5 +534,5 @@ func main() {
 		defer mpris.Close()
 	}
 
-	InitGui(&indexResponse.Indexes.Index, &playlistResponse.Playlists.Playlists, connection, player)
+	ui.Run()
 }
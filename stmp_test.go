@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// readTestConfig loads a config as if it had been read from stmp.toml, until
+// the end of the test
+func readTestConfig(t *testing.T, config string) {
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("toml")
+	if err := viper.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamingProfiles(t *testing.T) {
+	readTestConfig(t, `
+[streaming]
+maxBitRate = 320
+
+[streaming.profiles.mobile]
+maxBitRate = 128
+format = 'opus'
+
+[streaming.profiles.home]
+maxBitRate = 0
+`)
+
+	profiles := streamingProfiles()
+	want := []StreamingProfile{
+		{Name: "home", MaxBitRate: 0, Format: ""},
+		{Name: "mobile", MaxBitRate: 128, Format: "opus"},
+	}
+	if len(profiles) != len(want) {
+		t.Fatalf("got %d profiles, want %d", len(profiles), len(want))
+	}
+	for i := range want {
+		if profiles[i] != want[i] {
+			t.Errorf("profile %d is %+v, want %+v", i, profiles[i], want[i])
+		}
+	}
+}
+
+func TestStreamingProfilesWithoutProfiles(t *testing.T) {
+	readTestConfig(t, `
+[streaming]
+maxBitRate = 192
+format = 'mp3'
+`)
+
+	profiles := streamingProfiles()
+	want := StreamingProfile{Name: "default", MaxBitRate: 192, Format: "mp3"}
+	if len(profiles) != 1 || profiles[0] != want {
+		t.Errorf("got %+v, want only %+v", profiles, want)
+	}
+}
+
+func TestStreamingProfileIndex(t *testing.T) {
+	profiles := []StreamingProfile{{Name: "home"}, {Name: "mobile"}}
+
+	tests := []struct {
+		name  string
+		index int
+		ok    bool
+	}{
+		{"", 0, true},
+		{"home", 0, true},
+		{"mobile", 1, true},
+		{"Mobile", 1, true},
+		{"office", 0, false},
+	}
+	for _, test := range tests {
+		index, err := streamingProfileIndex(profiles, test.name)
+		if index != test.index || (err == nil) != test.ok {
+			t.Errorf("streamingProfileIndex(%q) = %d, %v, want %d, ok %t", test.name, index, err, test.index, test.ok)
+		}
+	}
+}
+
+func TestServerPassword(t *testing.T) {
+	const variable = "STMP_TEST_PASSWORD"
+	os.Setenv(variable, "from env")
+	t.Cleanup(func() { os.Unsetenv(variable) })
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		password string
+		ok       bool
+	}{
+		{"password", map[string]string{"password": "from config", "password_command": "echo from command", "password_env": variable}, "from config", true},
+		{"empty password", map[string]string{"password": "", "password_command": "echo from command"}, "", true},
+		{"command", map[string]string{"password_command": "echo from command", "password_env": variable}, "from command", true},
+		{"command first line", map[string]string{"password_command": "printf 'secret\\r\\nuser: admin\\n'"}, "secret", true},
+		{"failing command", map[string]string{"password_command": "exit 1", "password_env": variable}, "", false},
+		{"env", map[string]string{"password_env": variable}, "from env", true},
+		{"unset env", map[string]string{"password_env": "STMP_TEST_UNSET_PASSWORD"}, "", false},
+	}
+	for _, test := range tests {
+		config := viper.New()
+		for key, value := range test.settings {
+			config.Set(key, value)
+		}
+		password, err := serverPassword(config)
+		if password != test.password || (err == nil) != test.ok {
+			t.Errorf("%s: got %q, %v, want %q, ok %t", test.name, password, err, test.password, test.ok)
+		}
+	}
+}